@@ -0,0 +1,522 @@
+/*
+Contains the versioned REST API of the server, with games as resources created explicitly.
+*/
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GameBody describes a game, with squares and moves in coordinate notation like e2e4
+type GameBody struct {
+	ID    string     `json:"ID"`
+	FEN   string     `json:"FEN"`
+	Board [][]string `json:"Board"`
+	// Opponent is engine or human, and ToMove white or black
+	Opponent string `json:"Opponent"`
+	ToMove   string `json:"ToMove"`
+	// EngineColor, Level and Personality of the engine, not set in games between two players
+	EngineColor string   `json:"EngineColor,omitempty"`
+	Level       string   `json:"Level,omitempty"`
+	Personality string   `json:"Personality,omitempty"`
+	Moves       []string `json:"Moves"`
+	// Status is playing, checkmate or stalemate
+	Status string `json:"Status"`
+	// Check tells if the player to move is in check
+	Check  bool        `json:"Check"`
+	Clocks *ClocksBody `json:"Clocks,omitempty"`
+	// Takebacks left to the user, no limit when negative
+	Takebacks int `json:"Takebacks"`
+	// Seats taken in a game between two players, and Tokens of the players, only sent to the creator of the game
+	Seats  *SeatsBody  `json:"Seats,omitempty"`
+	Tokens *TokensBody `json:"Tokens,omitempty"`
+}
+
+// MoveBody is a move of the user
+type MoveBody struct {
+	Move string `json:"Move"`
+}
+
+// UndoBody tells how many plies, moves of either player, to take back
+type UndoBody struct {
+	Plies int `json:"Plies"`
+}
+
+// HintBody is the move suggested to the player to move
+type HintBody struct {
+	Move string `json:"Move"`
+	SAN  string `json:"SAN"`
+	// Score in pawns for the player to move
+	Score   float64 `json:"Score"`
+	Depth   int     `json:"Depth"`
+	Summary string  `json:"Summary"`
+}
+
+// AnalyseRequestBody is a position to analyse, given in FEN or as a board like the one of
+// MoveResponseBody with the color to move, and the limits of the search
+type AnalyseRequestBody struct {
+	FEN   string     `json:"FEN"`
+	Board [][]string `json:"Board"`
+	// ToMove is white or black, white when not set
+	ToMove string `json:"ToMove"`
+	// Depth searched, MaxDepth at most, and MoveTime in milliseconds, maxAnalyseTime at most.
+	// The search goes as deep as it can in MoveTime when Depth is not set.
+	Depth    int `json:"Depth"`
+	MoveTime int `json:"MoveTime"`
+	// MultiPV is the number of best lines to find, 1 when not set
+	MultiPV int `json:"MultiPV"`
+}
+
+// AnalyseResponseBody has the best move of a position, with the best lines when more than one was asked
+type AnalyseResponseBody struct {
+	BestMove string `json:"BestMove"`
+	SAN      string `json:"SAN"`
+	// Score in pawns for the player to move
+	Score float64        `json:"Score"`
+	Depth int            `json:"Depth"`
+	PV    []string       `json:"PV"`
+	Lines []AnalysisLine `json:"Lines,omitempty"`
+}
+
+// limits of the stateless analysis, so that one request cannot hold the server for long
+const (
+	maxAnalyseTime    = 10 * time.Second
+	maxAnalyseMultiPV = 10
+)
+
+// MovesBody has the moves played in a game
+type MovesBody struct {
+	Moves []string `json:"Moves"`
+}
+
+// body describes the game with the id
+func (game *Game) body(id string) GameBody {
+	res := GameBody{
+		ID:          id,
+		FEN:         game.fen(),
+		Opponent:    "engine",
+		ToMove:      colorName(game.orientColor(game.ToMove)),
+		EngineColor: game.engineColor(),
+		Personality: game.Personality,
+		Moves:       game.moveStrings(game.Moves),
+		Status:      game.status(),
+		Check:       game.Board.check(game.ToMove) == 1,
+		Clocks:      game.clocks(),
+		Takebacks:   game.Takebacks,
+	}
+	board, _ := game.view()
+	res.Board = board.getAsSlice()
+	if game.Level != nil {
+		res.Level = game.Level.Name
+	}
+	if game.Seats != nil {
+		res.Opponent = "human"
+		res.Seats = &SeatsBody{White: game.Seats.Joined[White], Black: game.Seats.Joined[Black]}
+	}
+	return res
+}
+
+// hintPosition gives the position to suggest a move in, on the board of the game, with the player to move:
+// the current position, where the user is to move in games against the engine, or the one after the
+// number of plies of the ply parameter, to review the game
+func (game *Game) hintPosition(ply string) (Board, Color, error) {
+	if err := game.assistance(); err != nil {
+		return Board{}, Undefined, err
+	}
+	board, player := game.Board, game.ToMove
+	if ply != "" {
+		plies, err := strconv.Atoi(ply)
+		if err != nil || plies < 0 || plies > len(game.Moves) {
+			return board, player, badRequest(fmt.Sprintf("ply must be between 0 and %d", len(game.Moves)))
+		}
+		if board, player, err = game.positionAt(plies); err != nil {
+			return board, player, err
+		}
+	} else if status := game.status(); game.Seats == nil && (status != playing || player != User) {
+		return board, player, &gameError{status: http.StatusConflict, code: codeGameOver, message: "The game is over, by " + status}
+	}
+	if game.EngineWhite {
+		board, player = board.mirror(), opponent(player)
+	}
+	return board, player, nil
+}
+
+// LegalMove is a move the user can play, with flags telling what it does. The engine plays without
+// castling, en passant or promotion, so these are never set by its rules for now.
+type LegalMove struct {
+	From      string `json:"From"`
+	To        string `json:"To"`
+	SAN       string `json:"SAN"`
+	Promotion string `json:"Promotion,omitempty"`
+	Capture   bool   `json:"Capture"`
+	Check     bool   `json:"Check"`
+	Castle    bool   `json:"Castle"`
+	EnPassant bool   `json:"EnPassant"`
+}
+
+// LegalMovesBody has the moves the user can play
+type LegalMovesBody struct {
+	Moves []LegalMove `json:"Moves"`
+}
+
+// createGame starts a game with the options of the request body, the engine moving first when
+// it plays white, and answers with the game and its id
+func createGame(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+	w.Header().Set("Content-Type", "application/json")
+	var options GameOptions
+	if err := decodeBody(r, &options, true); err != nil {
+		writeError(w, err)
+		return
+	}
+	game, err := newGame(options)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	id := newGameID()
+	if err := store.Put(id, game); err != nil {
+		writeError(w, err)
+		return
+	}
+	res := game.body(id)
+	if game.Seats != nil {
+		res.Tokens = &TokensBody{White: game.Seats.Tokens[White], Black: game.Seats.Tokens[Black]}
+	}
+	w.Header().Set("Location", "/v1/games/"+id)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(res)
+}
+
+// getGame answers with the game
+func getGame(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+	w.Header().Set("Content-Type", "application/json")
+	id := r.PathValue("id")
+	game, ok := store.Get(id)
+	if !ok {
+		writeError(w, errGameNotFound)
+		return
+	}
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(game.body(id))
+}
+
+// postMove plays the move of the user and the reply of the engine, and answers with the game.
+// In games between two players, the move is played for the player whose token the request carries.
+func postMove(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+	w.Header().Set("Content-Type", "application/json")
+	id := r.PathValue("id")
+	game, ok := store.Get(id)
+	if !ok {
+		writeError(w, errGameNotFound)
+		return
+	}
+	var body MoveBody
+	if err := decodeBody(r, &body, false); err != nil {
+		writeError(w, err)
+		return
+	}
+	move, err := parseMove(body.Move)
+	if err != nil {
+		writeError(w, badRequest(err.Error()))
+		return
+	}
+
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	if game.Seats != nil {
+		if err := game.Seats.turn(r, game.ToMove); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	if err := game.play(game.orientMove(move)); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := store.Put(id, game); err != nil {
+		log.Println("Could not save game", id, err)
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(game.body(id))
+}
+
+// undoMove takes back the last move of the user and the reply of the engine, or the number of plies
+// of the request body, and answers with the game
+func undoMove(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+	w.Header().Set("Content-Type", "application/json")
+	id := r.PathValue("id")
+	game, ok := store.Get(id)
+	if !ok {
+		writeError(w, errGameNotFound)
+		return
+	}
+	var body UndoBody
+	if err := decodeBody(r, &body, true); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	plies := body.Plies
+	if plies == 0 {
+		// the engine has no reply to take back when the game ended with the move of the user
+		plies = 2
+		if game.ToMove == Self {
+			plies = 1
+		}
+	}
+	if err := game.undo(plies); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := store.Put(id, game); err != nil {
+		log.Println("Could not save game", id, err)
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(game.body(id))
+}
+
+// getHint answers with the move the engine would play in place of the user, leaving the game as it is,
+// or in place of the player to move after the number of plies of the ply parameter.
+// In games between two players, the engine only helps once the game is over.
+func getHint(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+	w.Header().Set("Content-Type", "application/json")
+	game, ok := store.Get(r.PathValue("id"))
+	if !ok {
+		writeError(w, errGameNotFound)
+		return
+	}
+	// the game is not locked while searching, so that the hint does not hold up other requests
+	game.mutex.Lock()
+	board, player, err := game.hintPosition(r.URL.Query().Get("ply"))
+	game.mutex.Unlock()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	h, ok := hint(board, player, engineOptions, hintTime)
+	if !ok {
+		writeError(w, &gameError{status: http.StatusConflict, code: codeGameOver, message: "No move to suggest"})
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(HintBody{Move: h.Move.String(), SAN: h.SAN, Score: h.Score, Depth: h.Depth, Summary: h.summary()})
+}
+
+// getMoves answers with the moves played in the game
+func getMoves(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+	w.Header().Set("Content-Type", "application/json")
+	game, ok := store.Get(r.PathValue("id"))
+	if !ok {
+		writeError(w, errGameNotFound)
+		return
+	}
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MovesBody{game.moveStrings(game.Moves)})
+}
+
+// getLegalMoves answers with the moves the user can play, none when it is not their turn,
+// only those of the piece on the square of the from parameter when it is set.
+// In games between two players, they are the moves of the player to move.
+func getLegalMoves(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+	w.Header().Set("Content-Type", "application/json")
+	game, ok := store.Get(r.PathValue("id"))
+	if !ok {
+		writeError(w, errGameNotFound)
+		return
+	}
+	var from *Position
+	if square := r.URL.Query().Get("from"); square != "" {
+		position, err := parsePosition(square)
+		if err != nil {
+			writeError(w, badRequest(err.Error()))
+			return
+		}
+		from = &position
+	}
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+
+	res := LegalMovesBody{Moves: []LegalMove{}}
+	board, _ := game.view()
+	player := game.orientColor(game.ToMove)
+	if (game.Seats != nil || game.ToMove == User) && game.status() == playing {
+		for _, node := range board.generateNodes(player) {
+			if from != nil && node.oldPos != *from {
+				continue
+			}
+			move := Move{node.oldPos, node.newPos}
+			res.Moves = append(res.Moves, LegalMove{
+				From:    move.From.String(),
+				To:      move.To.String(),
+				SAN:     board.san(player, move),
+				Capture: board[move.To.row][move.To.col].getPlayer() == opponent(player),
+				Check:   node.board.check(opponent(player)) == 1,
+			})
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res)
+}
+
+// analysePosition finds the best moves of a position given in the request body, without a game
+func analysePosition(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+	w.Header().Set("Content-Type", "application/json")
+	var body AnalyseRequestBody
+	if err := decodeBody(r, &body, false); err != nil {
+		writeError(w, err)
+		return
+	}
+	if body.Depth < 0 || body.MoveTime < 0 || body.MultiPV < 0 {
+		writeError(w, badRequest("Depth, MoveTime and MultiPV cannot be negative"))
+		return
+	}
+	board, player, err := body.position()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	options := engineOptions
+	options.Level, options.Info, options.Stop = nil, nil, nil
+	options.MultiPV = min(max(body.MultiPV, 1), maxAnalyseMultiPV)
+	if body.Depth > 0 {
+		options.Depth = min(body.Depth, MaxDepth)
+	}
+	if body.MoveTime > 0 {
+		if body.Depth <= 0 {
+			options.Depth = MaxSearchDepth
+		}
+		options.Time = fixedTimeManager(min(time.Duration(body.MoveTime)*time.Millisecond, maxAnalyseTime))
+	}
+	lines := analyse(board, player, options)
+	if len(lines) == 0 || len(lines[0].Moves) == 0 {
+		writeError(w, &gameError{status: http.StatusUnprocessableEntity, code: codeGameOver, message: "No legal move in the position"})
+		return
+	}
+
+	best := lines[0]
+	res := AnalyseResponseBody{
+		BestMove: best.Moves[0].String(),
+		SAN:      board.san(player, best.Moves[0]),
+		Score:    best.scoreFor(player),
+		Depth:    best.Depth,
+		PV:       moveStrings(best.Moves),
+	}
+	if options.MultiPV > 1 {
+		for _, line := range lines {
+			res.Lines = append(res.Lines, AnalysisLine{Moves: moveStrings(line.Moves), Score: line.scoreFor(player), Depth: line.Depth})
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res)
+}
+
+// position reads the position of the request, from its FEN or its board
+func (body AnalyseRequestBody) position() (board Board, player Color, err error) {
+	switch {
+	case body.FEN != "" && body.Board != nil:
+		return board, player, badRequest("Either FEN or Board must be given, not both")
+	case body.FEN != "":
+		if board, player, err = parseFEN(body.FEN); err != nil {
+			return board, player, badRequest(err.Error())
+		}
+	case body.Board != nil:
+		if len(body.Board) != 8 {
+			return board, player, badRequest("Board must have 8 rows")
+		}
+		for _, row := range body.Board {
+			if len(row) != 8 {
+				return board, player, badRequest("Board must have 8 squares in every row")
+			}
+			for _, square := range row {
+				if getPieceFromString(square).String() != square {
+					return board, player, badRequest("Unknown piece " + square)
+				}
+			}
+		}
+		board = formBoardUsingSlice(body.Board)
+		switch strings.ToLower(body.ToMove) {
+		case "", "white":
+			player = White
+		case "black":
+			player = Black
+		default:
+			return board, player, badRequest("Unknown color " + body.ToMove)
+		}
+	default:
+		return board, player, badRequest("A FEN or a Board must be given")
+	}
+	return board, player, checkPosition(board, player)
+}
+
+// removeGame ends the game and removes it from the store. A game between two players is
+// only removed with the token of one of its players.
+func removeGame(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+	id := r.PathValue("id")
+	game, ok := store.Get(id)
+	if !ok {
+		writeError(w, errGameNotFound)
+		return
+	}
+	if game.Seats != nil {
+		game.mutex.Lock()
+		_, err := game.Seats.player(r)
+		game.mutex.Unlock()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	dropGame(w, id)
+}
+
+// dropGame removes the game with the id from the store
+func dropGame(w http.ResponseWriter, id string) {
+	if _, ok := store.Get(id); !ok {
+		writeError(w, errGameNotFound)
+		return
+	}
+	if err := store.Delete(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// preflight answers the requests browsers send before the requests of other origins
+func preflight(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// newGameID makes a random id for a game, long enough not to be guessed
+func newGameID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,603 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serve answers the request to target with the handler, the id of the game set in the path
+func serve(handler http.HandlerFunc, method string, target string, id string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.SetPathValue("id", id)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+// decode reads the JSON answer of the request
+func decode(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+		t.Fatalf("%s: %v", rec.Body.String(), err)
+	}
+}
+
+// checkError tells if the answer is the error with the status and the code, and gives it
+func checkError(t *testing.T, rec *httptest.ResponseRecorder, status int, code string) ErrorBody {
+	t.Helper()
+	var res ErrorResponseBody
+	if rec.Code != status {
+		t.Errorf("got status %d, want %d: %s", rec.Code, status, rec.Body.String())
+		return res.Error
+	}
+	decode(t, rec, &res)
+	if res.Error.Code != code || res.Error.Message == "" {
+		t.Errorf("got error %+v, want %s", res.Error, code)
+	}
+	return res.Error
+}
+
+// createTestGame creates a game with the options in JSON in an empty store
+func createTestGame(t *testing.T, options string) GameBody {
+	t.Helper()
+	store = newMemoryStore(0)
+	rec := serve(createGame, "POST", "/v1/games", "", options)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("%s: got status %d, %s", options, rec.Code, rec.Body.String())
+	}
+	var game GameBody
+	decode(t, rec, &game)
+	return game
+}
+
+func TestCreateGame(t *testing.T) {
+	for _, test := range []struct {
+		options string
+		status  int
+		moves   int
+	}{
+		{"", http.StatusCreated, 0},
+		{`{"Level": "beginner", "EngineColor": "white"}`, http.StatusCreated, 1},
+		{`{"FEN": "7k/8/8/8/8/8/8/7K w - - 0 1"}`, http.StatusCreated, 0},
+		{`{"EngineColor": "green"}`, http.StatusBadRequest, 0},
+		{`{"Level": "grandmaster"}`, http.StatusBadRequest, 0},
+		{`{"FEN": "8/8/8 w - - 0 1"}`, http.StatusBadRequest, 0},
+		{`{"FEN": "7k/8/8/8/8/8/8/8 w - - 0 1"}`, http.StatusUnprocessableEntity, 0},
+		{`{"FEN": "7k/8/8/8/8/8/8/K6K w - - 0 1"}`, http.StatusUnprocessableEntity, 0},
+		{`{"FEN": "P6k/8/8/8/8/8/8/7K w - - 0 1"}`, http.StatusUnprocessableEntity, 0},
+		{`{"FEN": "7k/8/8/8/8/8/8/p6K b - - 0 1"}`, http.StatusUnprocessableEntity, 0},
+		{`{"FEN": "k7/8/8/8/8/8/8/R6K w - - 0 1"}`, http.StatusUnprocessableEntity, 0},
+		{`{"Level": `, http.StatusBadRequest, 0},
+		{`{"Color": "white"}`, http.StatusBadRequest, 0},
+	} {
+		store = newMemoryStore(0)
+		rec := serve(createGame, "POST", "/v1/games", "", test.options)
+		if test.status == http.StatusUnprocessableEntity {
+			checkError(t, rec, test.status, codeInvalidPosition)
+			continue
+		}
+		if test.status != http.StatusCreated {
+			checkError(t, rec, test.status, codeBadRequest)
+			continue
+		}
+		if rec.Code != test.status {
+			t.Errorf("%s: got status %d, want %d", test.options, rec.Code, test.status)
+			continue
+		}
+		var game GameBody
+		decode(t, rec, &game)
+		if rec.Header().Get("Location") != "/v1/games/"+game.ID {
+			t.Errorf("%s: got location %s", test.options, rec.Header().Get("Location"))
+		}
+		if len(game.Moves) != test.moves || game.Status != playing {
+			t.Errorf("%s: got moves %v and status %s", test.options, game.Moves, game.Status)
+		}
+	}
+}
+
+func TestPlayMove(t *testing.T) {
+	game := createTestGame(t, `{"Level": "beginner"}`)
+	rec := serve(postMove, "POST", "/v1/games/"+game.ID+"/moves", game.ID, `{"Move": "e2e3"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, %s", rec.Code, rec.Body.String())
+	}
+	decode(t, rec, &game)
+	if len(game.Moves) != 2 || game.Moves[0] != "e2e3" {
+		t.Errorf("got moves %v, want e2e3 and the reply of the engine", game.Moves)
+	}
+
+	rec = serve(getMoves, "GET", "/v1/games/"+game.ID+"/moves", game.ID, "")
+	var moves MovesBody
+	decode(t, rec, &moves)
+	if len(moves.Moves) != 2 {
+		t.Errorf("got moves %v, want 2 moves", moves.Moves)
+	}
+
+	for _, test := range []struct {
+		move   string
+		status int
+		code   string
+	}{
+		{`{"Move": "e9e8"}`, http.StatusBadRequest, codeBadRequest},
+		{`{"Move": `, http.StatusBadRequest, codeBadRequest},
+		{"", http.StatusBadRequest, codeBadRequest},
+		{`{"Move": "e3e4", "Promotion": "q"}`, http.StatusBadRequest, codeBadRequest},
+		{`{"Move": "e3e5"}`, http.StatusUnprocessableEntity, codeIllegalMove},
+		{`{"Move": "e7e6"}`, http.StatusUnprocessableEntity, codeIllegalMove},
+	} {
+		rec := serve(postMove, "POST", "/v1/games/"+game.ID+"/moves", game.ID, test.move)
+		checkError(t, rec, test.status, test.code)
+	}
+
+	// an illegal move tells where the piece can go
+	rec = serve(postMove, "POST", "/v1/games/"+game.ID+"/moves", game.ID, `{"Move": "e3e5"}`)
+	if err := checkError(t, rec, http.StatusUnprocessableEntity, codeIllegalMove); err.Details == nil ||
+		err.Details.Square != "e3" || !reflect.DeepEqual(err.Details.LegalTargets, []string{"e4"}) {
+		t.Errorf("got details %+v, want e3 going to e4", err.Details)
+	}
+}
+
+func TestPlayMoveAfterGameOver(t *testing.T) {
+	// the user is mated on the back rank
+	game := createTestGame(t, `{"FEN": "7k/8/8/8/8/8/5PPP/r5K1 w - - 0 1"}`)
+	if game.Status != checkmate {
+		t.Fatalf("got status %s, want %s", game.Status, checkmate)
+	}
+	rec := serve(postMove, "POST", "/v1/games/"+game.ID+"/moves", game.ID, `{"Move": "g1f1"}`)
+	checkError(t, rec, http.StatusConflict, codeGameOver)
+}
+
+func TestRemoveGame(t *testing.T) {
+	game := createTestGame(t, "")
+	if rec := serve(removeGame, "DELETE", "/v1/games/"+game.ID, game.ID, ""); rec.Code != http.StatusNoContent {
+		t.Errorf("got status %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	for _, handler := range []http.HandlerFunc{getGame, getMoves, removeGame} {
+		checkError(t, serve(handler, "GET", "/v1/games/"+game.ID, game.ID, ""), http.StatusNotFound, codeNotFound)
+	}
+	rec := serve(postMove, "POST", "/v1/games/"+game.ID+"/moves", game.ID, `{"Move": "e2e3"}`)
+	checkError(t, rec, http.StatusNotFound, codeNotFound)
+}
+
+func TestGetLegalMoves(t *testing.T) {
+	for _, test := range []struct {
+		options, from string
+		moves         []string
+	}{
+		{"", "g1", []string{"Nf3", "Nh3"}},
+		{"", "e2", []string{"e3"}},
+		{"", "e4", nil},
+		// the squares are those of the board of the game when the engine plays white
+		{`{"Level": "beginner", "EngineColor": "white"}`, "g8", []string{"Nf6", "Nh6"}},
+		// no moves once the game is over
+		{`{"FEN": "7k/8/8/8/8/8/5PPP/r5K1 w - - 0 1"}`, "", nil},
+	} {
+		game := createTestGame(t, test.options)
+		rec := serve(getLegalMoves, "GET", "/v1/games/"+game.ID+"/legal-moves?from="+test.from, game.ID, "")
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s from %s: got status %d", test.options, test.from, rec.Code)
+			continue
+		}
+		var res LegalMovesBody
+		decode(t, rec, &res)
+		var sans []string
+		for _, move := range res.Moves {
+			if move.From != test.from {
+				t.Errorf("%s from %s: got %s from %s", test.options, test.from, move.SAN, move.From)
+			}
+			sans = append(sans, move.SAN)
+		}
+		sort.Strings(sans)
+		if !reflect.DeepEqual(sans, test.moves) {
+			t.Errorf("%s from %s: got %v, want %v", test.options, test.from, sans, test.moves)
+		}
+	}
+
+	game := createTestGame(t, "")
+	rec := serve(getLegalMoves, "GET", "/v1/games/"+game.ID+"/legal-moves?from=z9", game.ID, "")
+	checkError(t, rec, http.StatusBadRequest, codeBadRequest)
+	rec = serve(getLegalMoves, "GET", "/v1/games/"+game.ID+"/legal-moves", game.ID, "")
+	var res LegalMovesBody
+	decode(t, rec, &res)
+	// the engine moves pawns a single square
+	if len(res.Moves) != 12 {
+		t.Errorf("got %d moves at the start, want 12", len(res.Moves))
+	}
+}
+
+func TestLegalMoveFlags(t *testing.T) {
+	game := createTestGame(t, `{"FEN": "7k/8/8/4n3/8/8/8/4Q2K w - - 0 1"}`)
+	rec := serve(getLegalMoves, "GET", "/v1/games/"+game.ID+"/legal-moves?from=e1", game.ID, "")
+	var res LegalMovesBody
+	decode(t, rec, &res)
+	for _, move := range res.Moves {
+		if move.To != "e5" {
+			continue
+		}
+		if !move.Capture || !move.Check || move.SAN != "Qxe5+" {
+			t.Errorf("got %+v, want a capture with check", move)
+		}
+		return
+	}
+	t.Errorf("got %v, want e1e5", res.Moves)
+}
+
+// playTestMove plays the move of the user in the game and gives the game after the reply of the engine
+func playTestMove(t *testing.T, id string, move string) GameBody {
+	t.Helper()
+	rec := serve(postMove, "POST", "/v1/games/"+id+"/moves", id, `{"Move": "`+move+`"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s: got status %d, %s", move, rec.Code, rec.Body.String())
+	}
+	var game GameBody
+	decode(t, rec, &game)
+	return game
+}
+
+func TestUndoMove(t *testing.T) {
+	game := createTestGame(t, `{"Level": "beginner"}`)
+	playTestMove(t, game.ID, "e2e3")
+	rec := serve(undoMove, "POST", "/v1/games/"+game.ID+"/undo", game.ID, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, %s", rec.Code, rec.Body.String())
+	}
+	decode(t, rec, &game)
+	if len(game.Moves) != 0 || game.Takebacks != defaultTakebacks-1 {
+		t.Errorf("got moves %v and %d takebacks left, want none and %d", game.Moves, game.Takebacks, defaultTakebacks-1)
+	}
+
+	// taking back the reply of the engine only lets it move again
+	playTestMove(t, game.ID, "d2d3")
+	rec = serve(undoMove, "POST", "/v1/games/"+game.ID+"/undo", game.ID, `{"Plies": 1}`)
+	decode(t, rec, &game)
+	if len(game.Moves) != 2 || game.Moves[0] != "d2d3" {
+		t.Errorf("got moves %v, want d2d3 and a new reply", game.Moves)
+	}
+
+	for _, test := range []struct {
+		body   string
+		status int
+		code   string
+	}{
+		{`{"Plies": -1}`, http.StatusBadRequest, codeBadRequest},
+		{`{"Plies": 3}`, http.StatusConflict, codeTakebackRefused},
+		{`{"Moves": 1}`, http.StatusBadRequest, codeBadRequest},
+	} {
+		rec := serve(undoMove, "POST", "/v1/games/"+game.ID+"/undo", game.ID, test.body)
+		checkError(t, rec, test.status, test.code)
+	}
+}
+
+func TestUndoLimit(t *testing.T) {
+	for _, test := range []struct {
+		options string
+		undos   int
+		left    int
+	}{
+		{`{"Level": "beginner", "Takebacks": 1}`, 1, 0},
+		{`{"Level": "beginner", "Takebacks": 0}`, 0, 0},
+		{`{"Level": "beginner", "Takebacks": -1}`, 5, -1},
+	} {
+		game := createTestGame(t, test.options)
+		for i := 0; i < test.undos; i++ {
+			playTestMove(t, game.ID, "e2e3")
+			rec := serve(undoMove, "POST", "/v1/games/"+game.ID+"/undo", game.ID, "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("%s: takeback %d got status %d", test.options, i+1, rec.Code)
+			}
+			decode(t, rec, &game)
+		}
+		if game.Takebacks != test.left {
+			t.Errorf("%s: got %d takebacks left, want %d", test.options, game.Takebacks, test.left)
+		}
+		if test.left == 0 {
+			playTestMove(t, game.ID, "e2e3")
+			rec := serve(undoMove, "POST", "/v1/games/"+game.ID+"/undo", game.ID, "")
+			checkError(t, rec, http.StatusConflict, codeTakebackRefused)
+		}
+	}
+}
+
+func TestHintLeavesGame(t *testing.T) {
+	for _, options := range []string{`{"Level": "beginner"}`, `{"Level": "beginner", "EngineColor": "white"}`} {
+		game := createTestGame(t, options)
+		rec := serve(getHint, "GET", "/v1/games/"+game.ID+"/hint", game.ID, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: got status %d, %s", options, rec.Code, rec.Body.String())
+		}
+		var hint HintBody
+		decode(t, rec, &hint)
+
+		rec = serve(getLegalMoves, "GET", "/v1/games/"+game.ID+"/legal-moves", game.ID, "")
+		var legal LegalMovesBody
+		decode(t, rec, &legal)
+		found := false
+		for _, move := range legal.Moves {
+			found = found || (move.From+move.To == hint.Move && move.SAN == hint.SAN)
+		}
+		if !found {
+			t.Errorf("%s: the hint %s (%s) is not a legal move of the user", options, hint.Move, hint.SAN)
+		}
+
+		var after GameBody
+		decode(t, serve(getGame, "GET", "/v1/games/"+game.ID, game.ID, ""), &after)
+		if after.FEN != game.FEN || !reflect.DeepEqual(after.Moves, game.Moves) || after.Takebacks != game.Takebacks {
+			t.Errorf("%s: the hint changed the game from %+v to %+v", options, game, after)
+		}
+	}
+
+	game := createTestGame(t, `{"FEN": "7k/8/8/8/8/8/5PPP/r5K1 w - - 0 1"}`)
+	checkError(t, serve(getHint, "GET", "/v1/games/"+game.ID+"/hint", game.ID, ""), http.StatusConflict, codeGameOver)
+}
+
+// analyseTestPosition analyses the position of the request body
+func analyseTestPosition(body string) *httptest.ResponseRecorder {
+	return serve(analysePosition, "POST", "/v1/analyse", "", body)
+}
+
+func TestAnalysePosition(t *testing.T) {
+	board, _, _ := parseFEN("7k/6pp/8/8/8/8/6PP/R6K w - - 0 1")
+	rows, _ := json.Marshal(board.getAsSlice())
+	for _, body := range []string{
+		`{"FEN": "7k/6pp/8/8/8/8/6PP/R6K w - - 0 1", "Depth": 3}`,
+		`{"Board": ` + string(rows) + `, "ToMove": "white", "Depth": 3}`,
+	} {
+		rec := analyseTestPosition(body)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: got status %d, %s", body, rec.Code, rec.Body.String())
+		}
+		var res AnalyseResponseBody
+		decode(t, rec, &res)
+		if res.BestMove != "a1a8" || res.SAN != "Ra8#" || res.Lines != nil {
+			t.Errorf("%s: got %+v, want the mate Ra8#", body, res)
+		}
+	}
+}
+
+func TestAnalyseLimits(t *testing.T) {
+	rec := analyseTestPosition(`{"FEN": "` + startFEN + `", "Depth": 1, "MultiPV": 50}`)
+	var res AnalyseResponseBody
+	decode(t, rec, &res)
+	if len(res.Lines) != maxAnalyseMultiPV {
+		t.Errorf("got %d lines, want %d", len(res.Lines), maxAnalyseMultiPV)
+	}
+	rec = analyseTestPosition(`{"FEN": "7k/8/8/8/8/8/8/R6K w - - 0 1", "Depth": 100}`)
+	decode(t, rec, &res)
+	if res.Depth > MaxDepth {
+		t.Errorf("searched to depth %d, more than %d", res.Depth, MaxDepth)
+	}
+}
+
+func TestAnalyseInvalidPositions(t *testing.T) {
+	for _, test := range []struct {
+		body   string
+		status int
+		code   string
+	}{
+		{`{}`, http.StatusBadRequest, codeBadRequest},
+		{"", http.StatusBadRequest, codeBadRequest},
+		{`{"FEN": "` + startFEN + `", "Board": []}`, http.StatusBadRequest, codeBadRequest},
+		{`{"FEN": "8/8/8 w - - 0 1"}`, http.StatusBadRequest, codeBadRequest},
+		{`{"Board": [["K"]]}`, http.StatusBadRequest, codeBadRequest},
+		{`{"FEN": "` + startFEN + `", "Depth": 1, "Threads": 8}`, http.StatusBadRequest, codeBadRequest},
+		{`{"FEN": "` + startFEN + `", "Depth": -1}`, http.StatusBadRequest, codeBadRequest},
+		{`{"FEN": "` + startFEN + `", "MoveTime": -100}`, http.StatusBadRequest, codeBadRequest},
+		{`{"FEN": "` + startFEN + `", "MultiPV": -2}`, http.StatusBadRequest, codeBadRequest},
+		{`{"FEN": "7k/8/8/8/8/8/8/8 w - - 0 1"}`, http.StatusUnprocessableEntity, codeInvalidPosition},
+		{`{"FEN": "kk6/8/8/8/8/8/8/7K w - - 0 1"}`, http.StatusUnprocessableEntity, codeInvalidPosition},
+		{`{"FEN": "7k/8/8/8/8/8/8/P6K w - - 0 1"}`, http.StatusUnprocessableEntity, codeInvalidPosition},
+		{`{"FEN": "k7/8/8/8/8/8/8/R6K w - - 0 1"}`, http.StatusUnprocessableEntity, codeInvalidPosition},
+		// stalemate
+		{`{"FEN": "k7/8/1Q6/8/8/8/8/2K5 b - - 0 1"}`, http.StatusUnprocessableEntity, codeGameOver},
+	} {
+		checkError(t, analyseTestPosition(test.body), test.status, test.code)
+	}
+	board, _, _ := parseFEN(startFEN)
+	rows := board.getAsSlice()
+	rows[0][0] = "X"
+	body, _ := json.Marshal(AnalyseRequestBody{Board: rows})
+	checkError(t, analyseTestPosition(string(body)), http.StatusBadRequest, codeBadRequest)
+	body, _ = json.Marshal(AnalyseRequestBody{Board: board.getAsSlice(), ToMove: "green"})
+	checkError(t, analyseTestPosition(string(body)), http.StatusBadRequest, codeBadRequest)
+}
+
+func TestGameEvents(t *testing.T) {
+	game := createTestGame(t, `{"Level": "beginner"}`)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/games/{id}/events", gameEvents)
+	mux.HandleFunc("POST /v1/games/{id}/moves", postMove)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/v1/games/" + game.ID + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("got status %d and type %s", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+	// the events of the stream, as their type and their data
+	events := make(chan [2]string, eventBuffer)
+	go func() {
+		scanner := bufio.NewScanner(res.Body)
+		kind := ""
+		for scanner.Scan() {
+			line := scanner.Text()
+			if strings.HasPrefix(line, "event: ") {
+				kind = strings.TrimPrefix(line, "event: ")
+			} else if strings.HasPrefix(line, "data: ") {
+				events <- [2]string{kind, strings.TrimPrefix(line, "data: ")}
+			}
+		}
+		close(events)
+	}()
+
+	move, err := http.Post(server.URL+"/v1/games/"+game.ID+"/moves", "application/json", strings.NewReader(`{"Move": "e2e3"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	move.Body.Close()
+
+	var moves []MoveEventBody
+	searches := 0
+	timeout := time.After(5 * time.Second)
+	for len(moves) < 2 {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				t.Fatal("the stream ended")
+			}
+			switch e[0] {
+			case moveEvent:
+				var body MoveEventBody
+				if err := json.Unmarshal([]byte(e[1]), &body); err != nil {
+					t.Fatal(err)
+				}
+				moves = append(moves, body)
+			case searchEvent:
+				var body SearchEventBody
+				if err := json.Unmarshal([]byte(e[1]), &body); err != nil || len(body.PV) == 0 {
+					t.Errorf("got search event %s, %v", e[1], err)
+				}
+				searches++
+			}
+		case <-timeout:
+			t.Fatalf("got moves %v after 5s, want the move of the user and the reply", moves)
+		}
+	}
+	if moves[0].Player != "user" || moves[0].Move != "e2e3" || moves[0].SAN != "e3" || moves[1].Player != "engine" {
+		t.Errorf("got moves %+v, want e2e3 of the user and the reply of the engine", moves)
+	}
+	if searches == 0 {
+		t.Error("no search event before the reply of the engine")
+	}
+
+	checkError(t, serve(gameEvents, "GET", "/v1/games/unknown/events", "unknown", ""), http.StatusNotFound, codeNotFound)
+}
+
+// serveAs answers the request of the player with the token, or of no player when the token is empty
+func serveAs(handler http.HandlerFunc, method string, target string, id string, body string, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.SetPathValue("id", id)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func TestTwoPlayerGame(t *testing.T) {
+	game := createTestGame(t, `{"Opponent": "human"}`)
+	if game.Opponent != "human" || game.Tokens == nil || game.Tokens.White == "" || game.Tokens.Black == "" ||
+		game.Tokens.White == game.Tokens.Black || game.Seats == nil || game.Seats.White || game.Seats.Black {
+		t.Fatalf("got %+v, want a game between two players with their tokens and free seats", game)
+	}
+	white, black := game.Tokens.White, game.Tokens.Black
+	moves := "/v1/games/" + game.ID + "/moves"
+	join := "/v1/games/" + game.ID + "/join"
+
+	checkError(t, serveAs(postMove, "POST", moves, game.ID, `{"Move": "e2e3"}`, white), http.StatusConflict, codeWaiting)
+	checkError(t, serveAs(joinGame, "POST", join, game.ID, "", ""), http.StatusForbidden, codeForbidden)
+	checkError(t, serveAs(joinGame, "POST", join, game.ID, "", "wrong"), http.StatusForbidden, codeForbidden)
+	for _, seat := range []struct {
+		token string
+		color string
+	}{{white, "white"}, {black, "black"}, {black, "black"}} {
+		rec := serveAs(joinGame, "POST", join, game.ID, "", seat.token)
+		var joined JoinResponseBody
+		decode(t, rec, &joined)
+		if rec.Code != http.StatusOK || joined.Color != seat.color {
+			t.Errorf("got status %d and color %s, want %s", rec.Code, joined.Color, seat.color)
+		}
+	}
+
+	for _, test := range []struct {
+		move   string
+		token  string
+		status int
+		code   string
+	}{
+		{`{"Move": "e2e3"}`, "", http.StatusForbidden, codeForbidden},
+		{`{"Move": "e2e3"}`, "wrong", http.StatusForbidden, codeForbidden},
+		{`{"Move": "e7e6"}`, black, http.StatusConflict, codeNotYourTurn},
+		{`{"Move": "e2e3"}`, white, http.StatusOK, ""},
+		{`{"Move": "d2d3"}`, white, http.StatusConflict, codeNotYourTurn},
+		{`{"Move": "e7e6"}`, black, http.StatusOK, ""},
+	} {
+		rec := serveAs(postMove, "POST", moves, game.ID, test.move, test.token)
+		if test.status != http.StatusOK {
+			checkError(t, rec, test.status, test.code)
+		} else if rec.Code != http.StatusOK {
+			t.Errorf("%s: got status %d, %s", test.move, rec.Code, rec.Body.String())
+		}
+	}
+	var played GameBody
+	decode(t, serve(getGame, "GET", "/v1/games/"+game.ID, game.ID, ""), &played)
+	if !reflect.DeepEqual(played.Moves, []string{"e2e3", "e7e6"}) || played.ToMove != "white" || played.Tokens != nil {
+		t.Errorf("got moves %v with %s to move, want e2e3 e7e6 with white to move and no tokens", played.Moves, played.ToMove)
+	}
+
+	// neither player can take back a move
+	checkError(t, serveAs(undoMove, "POST", "/v1/games/"+game.ID+"/undo", game.ID, "", white), http.StatusConflict, codeTakebackRefused)
+
+	// only the players can remove the game
+	remove := "/v1/games/" + game.ID
+	checkError(t, serveAs(removeGame, "DELETE", remove, game.ID, "", ""), http.StatusForbidden, codeForbidden)
+	checkError(t, serveAs(removeGame, "DELETE", remove, game.ID, "", "wrong"), http.StatusForbidden, codeForbidden)
+	if rec := serveAs(removeGame, "DELETE", remove, game.ID, "", black); rec.Code != http.StatusNoContent {
+		t.Errorf("got status %d, want %d", rec.Code, http.StatusNoContent)
+	}
+
+	engine := createTestGame(t, `{"Level": "beginner"}`)
+	checkError(t, serveAs(joinGame, "POST", "/v1/games/"+engine.ID+"/join", engine.ID, "", white), http.StatusConflict, codeBadRequest)
+}
+
+// abandon makes the player to move of the stored game think for the duration
+func abandon(t *testing.T, id string, thought time.Duration) {
+	t.Helper()
+	game, ok := store.Get(id)
+	if !ok {
+		t.Fatalf("%s is not stored", id)
+	}
+	game.mutex.Lock()
+	game.turnStart = game.turnStart.Add(-thought)
+	game.mutex.Unlock()
+}
+
+func TestTimeoutWithoutMove(t *testing.T) {
+	game := createTestGame(t, `{"Level": "beginner", "Time": 60}`)
+	abandon(t, game.ID, 2*time.Minute)
+	decode(t, serve(getGame, "GET", "/v1/games/"+game.ID, game.ID, ""), &game)
+	if game.Status != timeout || game.Clocks == nil || *game.Clocks.User != 0 || *game.Clocks.Engine != 60000 {
+		t.Errorf("got status %s and clocks %+v, want a timeout with the clock of the user at 0", game.Status, game.Clocks)
+	}
+	moves := "/v1/games/" + game.ID + "/moves"
+	checkError(t, serve(postMove, "POST", moves, game.ID, `{"Move": "e2e3"}`), http.StatusConflict, codeOutOfTime)
+	checkError(t, serve(postMove, "POST", moves, game.ID, `{"Move": "e2e3"}`), http.StatusConflict, codeGameOver)
+
+	// the clocks of two players only run once both joined
+	game = createTestGame(t, `{"Opponent": "human", "Time": 60}`)
+	abandon(t, game.ID, 2*time.Minute)
+	var waiting GameBody
+	decode(t, serve(getGame, "GET", "/v1/games/"+game.ID, game.ID, ""), &waiting)
+	if waiting.Status != playing {
+		t.Errorf("got status %s before the players joined, want %s", waiting.Status, playing)
+	}
+	for _, token := range []string{game.Tokens.White, game.Tokens.Black} {
+		serveAs(joinGame, "POST", "/v1/games/"+game.ID+"/join", game.ID, "", token)
+	}
+	abandon(t, game.ID, 2*time.Minute)
+	decode(t, serve(getGame, "GET", "/v1/games/"+game.ID, game.ID, ""), &waiting)
+	if waiting.Status != timeout || *waiting.Clocks.White != 0 || *waiting.Clocks.Black != 60000 {
+		t.Errorf("got status %s and clocks %+v, want a timeout of white", waiting.Status, waiting.Clocks)
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Contains the maps of the squares attacked by the pieces, with bit row*8+col set for each square.
+*/
+package main
+
+import (
+	"math/bits"
+)
+
+// Steps of the pieces, as row and column offsets
+var (
+	knightSteps      = [8][2]int{{-2, -1}, {-2, 1}, {-1, -2}, {-1, 2}, {1, -2}, {1, 2}, {2, -1}, {2, 1}}
+	kingSteps        = [8][2]int{{-1, -1}, {-1, 0}, {-1, 1}, {0, -1}, {0, 1}, {1, -1}, {1, 0}, {1, 1}}
+	rookDirections   = [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	bishopDirections = [4][2]int{{-1, -1}, {-1, 1}, {1, -1}, {1, 1}}
+)
+
+// attacks gives the squares attacked by the piece at position, with bit row*8+col set.
+// Sliding pieces attack up to and including the first piece in their way, of either side.
+func (board Board) attacks(position Position) uint64 {
+	switch board[position.row][position.col].(type) {
+	case *Knight:
+		return board.stepAttacks(position, knightSteps[:])
+	case *King:
+		return board.stepAttacks(position, kingSteps[:])
+	case *Rook:
+		return board.slideAttacks(position, rookDirections[:])
+	case *Bishop:
+		return board.slideAttacks(position, bishopDirections[:])
+	case *Queen:
+		return board.slideAttacks(position, rookDirections[:]) | board.slideAttacks(position, bishopDirections[:])
+	case *Pawn:
+		forward := getForward(board[position.row][position.col].getPlayer())
+		return board.stepAttacks(position, [][2]int{{forward, -1}, {forward, 1}})
+	}
+	return 0
+}
+
+// stepAttacks gives the squares one step away from position
+func (board Board) stepAttacks(position Position, steps [][2]int) (attacks uint64) {
+	for _, step := range steps {
+		row, col := position.row+step[0], position.col+step[1]
+		if row >= 0 && row < 8 && col >= 0 && col < 8 {
+			attacks |= 1 << (row*8 + col)
+		}
+	}
+	return
+}
+
+// slideAttacks gives the squares along the directions from position, up to the first piece in the way
+func (board Board) slideAttacks(position Position, directions [][2]int) (attacks uint64) {
+	for _, direction := range directions {
+		row, col := position.row+direction[0], position.col+direction[1]
+		for row >= 0 && row < 8 && col >= 0 && col < 8 {
+			attacks |= 1 << (row*8 + col)
+			if board[row][col].getPlayer() != Undefined {
+				break
+			}
+			row, col = row+direction[0], col+direction[1]
+		}
+	}
+	return
+}
+
+// attackersTo gives the squares of the pieces of player attacking the square, whatever stands on it
+func (board Board) attackersTo(square Position, player Color) (attackers uint64) {
+	attackers |= board.piecesOf(board.stepAttacks(square, knightSteps[:]), player, &Knight{})
+	attackers |= board.piecesOf(board.stepAttacks(square, kingSteps[:]), player, &King{})
+	attackers |= board.piecesOf(board.slideAttacks(square, rookDirections[:]), player, &Rook{}, &Queen{})
+	attackers |= board.piecesOf(board.slideAttacks(square, bishopDirections[:]), player, &Bishop{}, &Queen{})
+	// pawns attack the square from a row behind it, as seen by player
+	backward := -getForward(player)
+	attackers |= board.piecesOf(board.stepAttacks(square, [][2]int{{backward, -1}, {backward, 1}}), player, &Pawn{})
+	return
+}
+
+// isAttacked tells if a piece of player attacks the square
+func (board Board) isAttacked(square Position, player Color) bool {
+	return board.attackersTo(square, player) != 0
+}
+
+// attackMap gives the squares attacked by the pieces of player
+func (board Board) attackMap(player Color) (squares uint64) {
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			if board[i][j].getPlayer() == player {
+				squares |= board.attacks(Position{i, j})
+			}
+		}
+	}
+	return
+}
+
+// piecesOf keeps the squares holding pieces of player of one of the kinds given
+func (board Board) piecesOf(squares uint64, player Color, kinds ...Piece) (pieces uint64) {
+	for squares != 0 {
+		square := bits.TrailingZeros64(squares)
+		squares &= squares - 1
+		piece := board[square/8][square%8]
+		if piece.getPlayer() != player {
+			continue
+		}
+		for _, kind := range kinds {
+			if piece.Index()%6 == kind.Index()%6 {
+				pieces |= 1 << square
+				break
+			}
+		}
+	}
+	return
+}
+
+// getPositions gives the positions of the squares
+func getPositions(squares uint64) (positions []Position) {
+	for squares != 0 {
+		square := bits.TrailingZeros64(squares)
+		squares &= squares - 1
+		positions = append(positions, Position{square / 8, square % 8})
+	}
+	return
+}
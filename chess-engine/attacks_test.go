@@ -0,0 +1,69 @@
+package main
+
+import (
+	"math/bits"
+	"reflect"
+	"testing"
+)
+
+func TestAttacks(t *testing.T) {
+	for _, test := range []struct {
+		fen      string
+		position Position
+		squares  int
+	}{
+		// a knight in the corner and in the center
+		{"k7/8/8/8/8/8/8/N6K w - - 0 1", Position{7, 0}, 2},
+		{"k7/8/8/8/3N4/8/8/7K w - - 0 1", Position{4, 3}, 8},
+		// sliding pieces attack up to the first piece in their way, the king included
+		{"k7/8/8/8/8/8/8/R6K w - - 0 1", Position{7, 0}, 14},
+		{"k7/8/8/8/3B4/8/8/7K w - - 0 1", Position{4, 3}, 13},
+		{"k7/8/8/8/3Q4/8/8/7K w - - 0 1", Position{4, 3}, 27},
+		{"k7/8/3p4/8/3Q4/8/8/7K w - - 0 1", Position{4, 3}, 25},
+		// pawns attack the two squares diagonally in front of them
+		{"k7/8/8/4p3/8/8/8/7K w - - 0 1", Position{3, 4}, 2},
+		{"k7/8/8/8/8/8/P7/7K w - - 0 1", Position{6, 0}, 1},
+	} {
+		board, _, err := parseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := bits.OnesCount64(board.attacks(test.position)); got != test.squares {
+			t.Errorf("%v in %s: got %d squares, want %d", test.position, test.fen, got, test.squares)
+		}
+	}
+}
+
+func TestAttackersTo(t *testing.T) {
+	for _, test := range []struct {
+		fen, square string
+		player      Color
+		attackers   []string
+	}{
+		// the rook behind the rook does not attack
+		{"k7/8/3p4/4n3/3P4/8/4R3/4R2K w - - 0 1", "e5", User, []string{"d4", "e2"}},
+		{"k7/8/3p4/4n3/3P4/8/4R3/4R2K w - - 0 1", "e5", Self, []string{"d6"}},
+		{"k7/8/q7/8/8/2N5/8/4K3 w - - 0 1", "e2", User, []string{"c3", "e1"}},
+		{"k7/8/q7/8/8/2N5/8/4K3 w - - 0 1", "e2", Self, []string{"a6"}},
+		// pawns attack forward only
+		{"k7/8/8/8/4p3/8/8/7K w - - 0 1", "d3", Self, []string{"e4"}},
+		{"k7/8/8/8/4p3/8/8/7K w - - 0 1", "d5", Self, nil},
+		{"k7/8/8/8/4P3/8/8/7K w - - 0 1", "d5", User, []string{"e4"}},
+	} {
+		board, _, err := parseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		square := getPositionFromInput(test.square)
+		var attackers []string
+		for _, position := range getPositions(board.attackersTo(square, test.player)) {
+			attackers = append(attackers, position.String())
+		}
+		if !reflect.DeepEqual(attackers, test.attackers) {
+			t.Errorf("%s in %s: got %v, want %v", test.square, test.fen, attackers, test.attackers)
+		}
+		if attacked := board.isAttacked(square, test.player); attacked != (len(test.attackers) > 0) {
+			t.Errorf("%s in %s: got attacked %v", test.square, test.fen, attacked)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+/*
+Contains the benchmark of the search run from the command line.
+*/
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// benchGames are the moves played to reach the benchmark positions
+var benchGames = [][]string{
+	{},
+	{"e2e3", "e7e6", "g1f3", "g8f6", "f1c4", "f8c5"},
+	{"d2d3", "d7d6", "c1g5", "c8g4", "b1c3", "b8c6", "d1d2", "d8d7"},
+}
+
+// bench prints the time taken to search the benchmark positions to every depth
+// up to depth, doubling the threads up to maxThreads, with the speedup over a single thread.
+func bench(depth int, maxThreads int) {
+	positions := []Board{}
+	for _, moves := range benchGames {
+		board := Board{}
+		board.initialise()
+		for _, move := range moves {
+			board.makeMove(getPositionFromInput(move[:2]), getPositionFromInput(move[2:]))
+		}
+		positions = append(positions, board)
+	}
+
+	single := make([]time.Duration, depth+1)
+	fmt.Printf("threads\tdepth\ttime\tspeedup\n")
+	for threads := 1; threads <= maxThreads; threads *= 2 {
+		for d := 1; d <= depth; d++ {
+			clearCache()
+			start := time.Now()
+			for _, board := range positions {
+				search(board, Self, SearchOptions{Depth: d, Threads: threads})
+			}
+			elapsed := time.Since(start)
+			if threads == 1 {
+				single[d] = elapsed
+			}
+			fmt.Printf("%d\t%d\t%v\t%.2fx\n", threads, d, elapsed.Round(time.Millisecond),
+				float64(single[d])/float64(elapsed))
+		}
+	}
+}
@@ -4,7 +4,9 @@ Contains chess board related methods and types.
 package main
 
 import (
+	"errors"
 	"fmt"
+	"unicode"
 )
 
 // Color of piece
@@ -24,8 +26,93 @@ type Board [8][8]Piece
 
 // Position on board of form a1, b4 etc...
 type Position struct {
-	row int `json:"row,omitempty"`
-	col int `json:"col,omitempty"`
+	row int
+	col int
+}
+
+// Move of a piece from one position to another
+type Move struct {
+	From Position
+	To   Position
+}
+
+func (position Position) String() string {
+	return fmt.Sprintf("%c%d", 'a'+position.col, 8-position.row)
+}
+
+// String gives the move in coordinate notation, like e2e3
+func (move Move) String() string {
+	return move.From.String() + move.To.String()
+}
+
+// parsePosition reads a position like e2
+func parsePosition(position string) (Position, error) {
+	if len(position) != 2 || position[0] < 'a' || position[0] > 'h' || position[1] < '1' || position[1] > '8' {
+		return Position{}, errors.New("Invalid square " + position)
+	}
+	return Position{int('8' - position[1]), int(position[0] - 'a')}, nil
+}
+
+// parseMove reads a move in coordinate notation, like e2e4
+func parseMove(move string) (Move, error) {
+	if len(move) != 4 {
+		return Move{}, errors.New("Invalid move " + move)
+	}
+	from, err := parsePosition(move[:2])
+	if err != nil {
+		return Move{}, err
+	}
+	to, err := parsePosition(move[2:])
+	if err != nil {
+		return Move{}, err
+	}
+	return Move{from, to}, nil
+}
+
+// mirror gives the position on the board of the other side, with the ranks reversed
+func (position Position) mirror() Position {
+	return Position{7 - position.row, position.col}
+}
+
+// mirror gives the move on the board of the other side
+func (move Move) mirror() Move {
+	return Move{move.From.mirror(), move.To.mirror()}
+}
+
+// mirror gives the same position for the other side, with the colors swapped and the ranks reversed
+func (board Board) mirror() (mirrored Board) {
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			if board[i][j].getPlayer() == Undefined {
+				mirrored[7-i][j] = &Empty{}
+				continue
+			}
+			c := getFENFromPiece(board[i][j])
+			if unicode.IsUpper(c) {
+				c = unicode.ToLower(c)
+			} else {
+				c = unicode.ToUpper(c)
+			}
+			mirrored[7-i][j] = getPieceFromFEN(c)
+		}
+	}
+	return
+}
+
+// moveStrings gives the moves in coordinate notation
+func moveStrings(moves []Move) []string {
+	strs := []string{}
+	for _, move := range moves {
+		strs = append(strs, move.String())
+	}
+	return strs
+}
+
+func opponent(player Color) Color {
+	if player == User {
+		return Self
+	}
+	return User
 }
 
 func getColor(position Position) Color {
@@ -267,3 +354,13 @@ func (board Board) getBRDiagonal(position Position) (dPos []Position) {
 	}
 	return dPos
 }
+
+// contains tells if the position is one of the positions
+func contains(positions []Position, loc Position) bool {
+	for _, v := range positions {
+		if v == loc {
+			return true
+		}
+	}
+	return false
+}
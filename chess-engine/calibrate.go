@@ -0,0 +1,73 @@
+/*
+Contains the self-play harness estimating the ratings of the difficulty levels.
+*/
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// maxGamePlies ends self-play games still going as draws
+const maxGamePlies = 200
+
+// calibrate plays games between each level and the one below it, half of them with each color,
+// and prints the rating of every level relative to the weakest one estimated from the scores.
+func calibrate(games int) {
+	rating := 0.0
+	fmt.Printf("level\tscore\trating\tnominal\n")
+	fmt.Printf("%s\t-\t%.0f\t%d\n", levels[0].Name, rating, levels[0].Elo)
+	for i := 1; i < len(levels); i++ {
+		weaker, stronger := &levels[i-1], &levels[i]
+		score := 0.0
+		for game := 0; game < games; game++ {
+			if game%2 == 0 {
+				score += playGame(stronger, weaker, nil)
+			} else {
+				score += 1 - playGame(weaker, stronger, nil)
+			}
+		}
+		rating += eloDifference(score, games)
+		fmt.Printf("%s\t%.1f/%d\t%.0f\t%d\n", stronger.Name, score, games, rating, stronger.Elo)
+	}
+}
+
+// playGame plays a game between two levels from the start position
+// and gives the score of white: 1 for a win, 0.5 for a draw and 0 for a loss.
+// Every position reached is given to record when set.
+func playGame(white *Level, black *Level, record func(Board)) float64 {
+	board := Board{}
+	board.initialise()
+	player := User
+	for ply := 0; ply < maxGamePlies; ply++ {
+		level := white
+		if player == Self {
+			level = black
+		}
+		oldPos, newPos, _ := search(board, player, SearchOptions{Level: level})
+		if oldPos == newPos {
+			// stalemate, otherwise mated or giving up against a mate it can't escape
+			if board.check(player) == 0 && len(board.generateNodes(player)) == 0 {
+				return 0.5
+			}
+			if player == User {
+				return 0
+			}
+			return 1
+		}
+		board.makeMove(oldPos, newPos)
+		player = opponent(player)
+		if record != nil {
+			record(board)
+		}
+	}
+	return 0.5
+}
+
+// eloDifference estimates how much higher a player scoring score out of games is rated.
+// The score is kept half a point away from a clean sweep, which has no finite difference.
+func eloDifference(score float64, games int) float64 {
+	ratio := score / float64(games)
+	ratio = math.Max(0.5/float64(games), math.Min(1-0.5/float64(games), ratio))
+	return 400 * math.Log10(ratio/(1-ratio))
+}
@@ -3,14 +3,13 @@ package main
 import (
 	"errors"
 	"math"
+	"math/bits"
 	"math/rand"
 	"reflect"
-	"time"
+	"sort"
 	"sync"
 )
 
-var mutex = &sync.Mutex{}
-
 // TODO: Check and protect from CHECK to King.
 const (
 	//Self Color
@@ -36,24 +35,124 @@ type Tree struct {
 
 /** Zorbist hashing here*/
 
-var cache = initCache()
+// defaultCacheSize is the number of positions kept in the search cache, unless set otherwise,
+// and deterministicCacheSize the number kept in the own cache of a deterministic search
+const (
+	defaultCacheSize       = 1 << 18
+	deterministicCacheSize = 1 << 16
+)
+
+// cacheStripes is the number of locks of the shared cache, each one guarding the slots of the keys
+// that are equal modulo cacheStripes, so that threads seldom wait on each other
+const cacheStripes = 64
+
+var cache = initCache(defaultCacheSize)
+
+var cacheLocks [cacheStripes]sync.Mutex
+
+// zorbistRand draws the keys of positions from a fixed seed, so that they are the same in every run
+var zorbistRand = rand.New(rand.NewSource(1))
 
 var zorbistTable = initZorbist()
 
-func initCache() (cache [MaxDepth]map[int]float64) {
-	for i := 0; i < MaxDepth; i++ {
-		cache[i] = make(map[int]float64)
+var zorbistSide = zorbistRand.Intn(int(math.Pow(2, 31)))
+
+// bound tells how a cached score relates to the real score of the position
+type bound int
+
+const (
+	exact bound = iota
+	// lowerBound scores caused a beta cut-off, the real score may be higher
+	lowerBound
+	// upperBound scores failed low, the real score may be lower
+	upperBound
+)
+
+// cacheEntry holds the result of searching a position to a given depth
+type cacheEntry struct {
+	key    int
+	filled bool
+	depth  int
+	score  float64
+	bound  bound
+	oldPos Position
+	newPos Position
+}
+
+// initCache makes a cache of size positions, rounded down to a power of two
+func initCache(size int) []cacheEntry {
+	return make([]cacheEntry, 1<<(bits.Len(uint(max(size, 1)))-1))
+}
+
+// setCacheSize replaces the cache by an empty one of size positions, at least one for each lock
+func setCacheSize(size int) {
+	lockCache()
+	cache = initCache(max(size, cacheStripes))
+	unlockCache()
+}
+
+// clearCache forgets every position searched so far
+func clearCache() {
+	lockCache()
+	cache = initCache(len(cache))
+	unlockCache()
+}
+
+// lockCache takes every lock of the shared cache, to replace it
+func lockCache() {
+	for i := range cacheLocks {
+		cacheLocks[i].Lock()
 	}
-	return
 }
 
-func initZorbist() (zorbistTable [8][8][12]int) {
+// unlockCache releases the locks taken by lockCache
+func unlockCache() {
+	for i := range cacheLocks {
+		cacheLocks[i].Unlock()
+	}
+}
+
+// cacheLock gives the lock of the slot of the key in the shared cache
+func cacheLock(key int) *sync.Mutex {
+	return &cacheLocks[key&(cacheStripes-1)]
+}
+
+func probeCache(key int) (cacheEntry, bool) {
+	lock := cacheLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+	return lookUp(cache, key)
+}
 
-	rand.Seed(time.Now().UnixNano())
+// lookUp finds the entry of the key in the cache
+func lookUp(cache []cacheEntry, key int) (cacheEntry, bool) {
+	entry := cache[key&(len(cache)-1)]
+	return entry, entry.filled && entry.key == key
+}
+
+// storeCache keeps the entry in the shared cache, like keep
+func storeCache(key int, entry cacheEntry) {
+	lock := cacheLock(key)
+	lock.Lock()
+	keep(cache, key, entry)
+	lock.Unlock()
+}
+
+// keep puts the entry in the cache unless a deeper search of the position is already cached.
+// The entry replaces any other position kept in its slot, so the cache never grows.
+func keep(cache []cacheEntry, key int, entry cacheEntry) {
+	slot := &cache[key&(len(cache)-1)]
+	if !slot.filled || slot.key != key || slot.depth <= entry.depth {
+		entry.key, entry.filled = key, true
+		*slot = entry
+	}
+}
+
+func initZorbist() (zorbistTable [8][8][12]int) {
 	for i := 0; i < 8; i++ {
 		for j := 0; j < 8; j++ {
 			for k := 0; k < 12; k++ {
-				zorbistTable[i][j][k] = rand.Intn(int(math.Pow(2, 31)))
+				zorbistTable[i][j][k] = zorbistRand.Intn(int(math.Pow(2, 31)))
 			}
 		}
 	}
@@ -73,31 +172,54 @@ func (board Board) hash() int {
 	return hash
 }
 
-func miniMax(depth int, tree Tree, player Color,
+// key hashes the board together with the player to move
+func (board Board) key(player Color) int {
+	if player == User {
+		return board.hash() ^ zorbistSide
+	}
+	return board.hash()
+}
+
+func (s *searcher) miniMax(depth int, tree Tree, player Color,
 	alpha float64, beta float64) (oldPos Position, newPos Position, score float64) {
-	if depth == MaxDepth {
-		return tree.oldPos, tree.newPos, tree.board.evaluate()
+	s.nodes++
+	if (s.time != nil && s.nodes%256 == 0 && s.time.timeUp()) || (s.maxNodes > 0 && s.nodes >= s.maxNodes) {
+		s.limited = true
+	}
+	if depth == s.depth {
+		if s.noise > 0 {
+			return tree.oldPos, tree.newPos, s.evaluate(depth, tree.board) + (2*s.rand.Float64()-1)*s.noise
+		}
+		return tree.oldPos, tree.newPos, s.evaluate(depth, tree.board)
+	}
+	if s.stopped() {
+		return tree.oldPos, tree.newPos, 0
 	}
 
+	key := tree.board.key(player) ^ s.salt
+	entry, hit := s.probeCache(key)
+	// the root is always searched so that a move is returned
+	if hit && depth > 0 && entry.depth >= s.depth-depth {
+		if entry.bound == exact ||
+			(entry.bound == lowerBound && entry.score >= beta) ||
+			(entry.bound == upperBound && entry.score <= alpha) {
+			return tree.oldPos, tree.newPos, entry.score
+		}
+	}
+	tree.nodes = s.orderNodes(tree.board, tree.board.generateNodes(player), entry, hit)
+	if depth == 0 {
+		tree.nodes = s.excludeNodes(tree.nodes)
+	}
+	origAlpha, origBeta := alpha, beta
+
 	if player == Self { //maximizer
 		best := MIN
 		index := -1
-		tree.nodes = append(tree.nodes, tree.board.generateNodes(Self)...)
 		for i := 0; i < len(tree.nodes); i++ {
-			var val float64
-
-			mutex.Lock()
-			cachedVal, hit := cache[depth][tree.nodes[i].board.hash()]
-			mutex.Unlock()
-			if hit == true {
-				val = cachedVal
-				//fmt.Println("Cache hit max")
-			} else {
-				_, _, newVal := miniMax(depth+1, tree.nodes[i], User, alpha, beta)
-				val = newVal
-				mutex.Lock()
-				cache[depth][tree.nodes[i].board.hash()] = newVal
-				mutex.Unlock()
+			s.makeMove(depth, tree.board, tree.nodes[i])
+			_, _, val := s.miniMax(depth+1, tree.nodes[i], User, alpha, beta)
+			if s.stopped() {
+				return tree.oldPos, tree.newPos, 0
 			}
 			//experimental - for risk taking
 			//if First(tree.board.movePiece(tree.nodes[i].oldPos, tree.nodes[i].newPos)).check(User) == 1 && val > alpha+2  {
@@ -117,27 +239,19 @@ func miniMax(depth int, tree Tree, player Color,
 			}
 		}
 		if index == -1 {
+			s.store(key, depth, MIN, origAlpha, origBeta, Tree{})
 			return tree.oldPos, tree.newPos, MIN
 		}
+		s.store(key, depth, best, origAlpha, origBeta, tree.nodes[index])
 		return tree.nodes[index].oldPos, tree.nodes[index].newPos, best
 	}
 	best := MAX
 	index := -1
-	tree.nodes = append(tree.nodes, tree.board.generateNodes(User)...)
 	for i := 0; i < len(tree.nodes); i++ {
-		var val float64
-		mutex.Lock()
-		cachedVal, hit := cache[depth][tree.nodes[i].board.hash()]
-		mutex.Unlock()
-		if  hit == true {
-			val = cachedVal
-			//fmt.Println("Cache hit mini")
-		} else {
-			_, _, newVal := miniMax(depth+1, tree.nodes[i], Self, alpha, beta)
-			val = newVal
-			mutex.Lock()
-			cache[depth][tree.nodes[i].board.hash()] = newVal
-			mutex.Unlock()
+		s.makeMove(depth, tree.board, tree.nodes[i])
+		_, _, val := s.miniMax(depth+1, tree.nodes[i], Self, alpha, beta)
+		if s.stopped() {
+			return tree.oldPos, tree.newPos, 0
 		}
 		//experimental - for risk taking
 		//if First(tree.board.movePiece(tree.nodes[i].oldPos, tree.nodes[i].newPos)).check(Self) == 1 && val < beta-2 {
@@ -158,11 +272,67 @@ func miniMax(depth int, tree Tree, player Color,
 	}
 	if index == -1 {
 		//fmt.Println("Index = -1")
+		s.store(key, depth, MAX, origAlpha, origBeta, Tree{})
 		return tree.oldPos, tree.newPos, MAX
 	}
+	s.store(key, depth, best, origAlpha, origBeta, tree.nodes[index])
 	return tree.nodes[index].oldPos, tree.nodes[index].newPos, best
 }
 
+// store caches the score of a node searched with the window (alpha, beta) along with its best move
+func (s *searcher) store(key int, depth int, score float64, alpha float64, beta float64, best Tree) {
+	// the best of the moves left at the root is not the score of the position
+	if depth == 0 && len(s.exclude) > 0 {
+		return
+	}
+	entry := cacheEntry{depth: s.depth - depth, score: score, oldPos: best.oldPos, newPos: best.newPos}
+	if score <= alpha {
+		entry.bound = upperBound
+	} else if score >= beta {
+		entry.bound = lowerBound
+	}
+	s.storeCache(key, entry)
+}
+
+// orderNodes shuffles the nodes of the board, puts the captures winning material by SEE first,
+// most material first, and moves the best move of a previous search to the front
+func (s *searcher) orderNodes(board Board, nodes []Tree, entry cacheEntry, hit bool) []Tree {
+	shuffle(nodes, s.rand)
+	gains := make(map[Move]float64)
+	for _, node := range nodes {
+		if board[node.newPos.row][node.newPos.col].Index() != -1 {
+			gains[Move{node.oldPos, node.newPos}] = board.see(Move{node.oldPos, node.newPos})
+		}
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return gains[Move{nodes[i].oldPos, nodes[i].newPos}] > gains[Move{nodes[j].oldPos, nodes[j].newPos}]
+	})
+	if !hit {
+		return nodes
+	}
+	for i := range nodes {
+		if nodes[i].oldPos == entry.oldPos && nodes[i].newPos == entry.newPos {
+			nodes[0], nodes[i] = nodes[i], nodes[0]
+			break
+		}
+	}
+	return nodes
+}
+
+// excludeNodes removes the moves of the lines already found from the nodes of the root
+func (s *searcher) excludeNodes(nodes []Tree) []Tree {
+	if len(s.exclude) == 0 {
+		return nodes
+	}
+	kept := []Tree{}
+	for _, node := range nodes {
+		if !containsMove(s.exclude, Move{node.oldPos, node.newPos}) {
+			kept = append(kept, node)
+		}
+	}
+	return kept
+}
+
 func (board Board) generateNodes(color Color) []Tree {
 	nodes := []Tree{}
 
@@ -180,142 +350,36 @@ func (board Board) generateNodes(color Color) []Tree {
 			}
 		}
 	}
-	return shuffle(nodes)
+	return nodes
 }
 
 // enhance this algo
-// use blocked pawns + isolated pawns + doubled pawns
-func (board Board) evaluate() float64 {
-	kingWt := 200.0 * float64(board.getPieceDiff(reflect.TypeOf(&King{})))
-	queenWt := 9.0 * float64(board.getPieceDiff(reflect.TypeOf(&Queen{})))
-	rookWt := 5.0 * float64(board.getPieceDiff(reflect.TypeOf(&Rook{})))
-	bishopWt := 3.0 * float64(board.getPieceDiff(reflect.TypeOf(&Bishop{})))
-	knightWt := 3.0 * float64(board.getPieceDiff(reflect.TypeOf(&Knight{})))
-	pawnWt := 1.0 * float64(board.getPieceDiff(reflect.TypeOf(&Pawn{})))
-	checkWt := 8 * float64((board.check(User) - board.check(Self)))
-	//mobility := 0.1 * float64(len(board.generateNodes(Self))-len(board.generateNodes(User)))
-	return float64(kingWt + queenWt + rookWt + bishopWt + knightWt + pawnWt + checkWt)
+func (board Board) evaluate(weights *Weights) float64 {
+	kingWt := weights.Material[King{Self}.Index()] * float64(board.getPieceDiff(reflect.TypeOf(&King{})))
+	queenWt := weights.Material[Queen{Self}.Index()] * float64(board.getPieceDiff(reflect.TypeOf(&Queen{})))
+	rookWt := weights.Material[Rook{Self}.Index()] * float64(board.getPieceDiff(reflect.TypeOf(&Rook{})))
+	bishopWt := weights.Material[Bishop{Self}.Index()] * float64(board.getPieceDiff(reflect.TypeOf(&Bishop{})))
+	knightWt := weights.Material[Knight{Self}.Index()] * float64(board.getPieceDiff(reflect.TypeOf(&Knight{})))
+	pawnWt := weights.Material[Pawn{Self}.Index()] * float64(board.getPieceDiff(reflect.TypeOf(&Pawn{})))
+	phase := board.getPhase()
+	pstWt := board.getPSTScore(weights, phase)
+	pawnStructureWt := board.getPawnScore(weights, phase)
+	kingSafetyWt := board.getKingSafetyScore(weights, phase)
+	activityWt := board.getActivityScore(weights, phase)
+	return float64(kingWt + queenWt + rookWt + bishopWt + knightWt + pawnWt + pstWt + pawnStructureWt + kingSafetyWt + activityWt)
 }
 
 func (board Board) check(player Color) int {
-	var opponent Color
-	if player == User {
-		opponent = Self
-	} else {
-		opponent = User
-	}
 	pos, err := board.findPiece(King{player})
 	if err != nil {
 		return 1
 	}
-
-	blDiagonalPos := getLastElement(board.getBLDiagonal(pos))
-	if board.checkDialogalDanger(blDiagonalPos, opponent) {
-		return 1
-	}
-
-	brDiagonalPos := getLastElement(board.getBRDiagonal(pos))
-	if board.checkDialogalDanger(brDiagonalPos, opponent) {
-		return 1
-	}
-
-	flDiagonalPos := getLastElement(board.getFLDiagonal(pos))
-	if board.checkDialogalDanger(flDiagonalPos, opponent) {
-		return 1
-	}
-
-	frDiagonalPos := getLastElement(board.getFRDiagonal(pos))
-	if board.checkDialogalDanger(frDiagonalPos, opponent) {
-		return 1
-	}
-
-	ltPos := getLastElement(board.getLtMoves(pos))
-	if board.checkAxialDanger(ltPos, opponent) {
-		return 1
-	}
-
-	fwdPos := getLastElement(board.getFwMoves(pos))
-	if board.checkAxialDanger(fwdPos, opponent) {
-		return 1
-	}
-
-	bkPos := getLastElement(board.getBkMoves(pos))
-	if board.checkAxialDanger(bkPos, opponent) {
-		return 1
-	}
-
-	rtPos := getLastElement(board.getRtMoves(pos))
-	if board.checkAxialDanger(rtPos, opponent) {
+	if board.isAttacked(pos, opponent(player)) {
 		return 1
 	}
-
-	knightMoves, _ := Knight{}.getAllMoves(board, pos)
-	for _, move := range knightMoves {
-		if board[move.row][move.col].String() == (&Knight{opponent}).String() {
-			return 1
-		}
-	}
-
-	kingMoves, _ := King{}.getAllMoves(board, pos)
-	for _, move := range kingMoves {
-		if board[move.row][move.col].String() == (&King{opponent}).String() {
-			return 1
-		}
-	}
-
-	//Pawns
-	if player == Self {
-		if pos.row+1 < 8 && pos.col-1 >= 0 && board[pos.row+1][pos.col-1].String() == (&Pawn{User}).String() {
-			return 1
-		}
-		if pos.row+1 < 8 && pos.col+1 < 8 && board[pos.row+1][pos.col+1].String() == (&Pawn{User}).String() {
-			return 1
-		}
-	} else {
-		if pos.row-1 >= 0 && pos.col-1 >= 0 && board[pos.row-1][pos.col-1].String() == (&Pawn{Self}).String() {
-			return 1
-		}
-		if pos.row-1 >= 0 && pos.col+1 < 8 && board[pos.row-1][pos.col+1].String() == (&Pawn{Self}).String() {
-			return 1
-		}
-	}
-
 	return 0
 }
 
-func (board Board) checkDialogalDanger(position Position, opponent Color) bool {
-	if position.row == -1 || position.col == -1 {
-		return false
-	}
-	if board[position.row][position.col].getPlayer() == opponent {
-		piece := reflect.TypeOf(board[position.row][position.col])
-		if piece == reflect.TypeOf(&Queen{}) || piece == reflect.TypeOf(&Bishop{}) {
-			return true
-		}
-	}
-	return false
-}
-
-func (board Board) checkAxialDanger(position Position, opponent Color) bool {
-	if position.row == -1 || position.col == -1 {
-		return false
-	}
-	if board[position.row][position.col].getPlayer() == opponent {
-		piece := reflect.TypeOf(board[position.row][position.col])
-		if piece == reflect.TypeOf(&Queen{}) || piece == reflect.TypeOf(&Rook{}) {
-			return true
-		}
-	}
-	return false
-}
-
-func getLastElement(positions []Position) Position {
-	if len(positions) == 0 {
-		return Position{-1, -1}
-	}
-	return positions[len(positions)-1]
-}
-
 func (board Board) findPiece(piece Piece) (Position, error) {
 	for i := 0; i < 8; i++ {
 		for j := 0; j < 8; j++ {
@@ -349,11 +413,10 @@ func First(b Board, _ error) Board {
 }
 
 // shuffle shuffles the elements of an array in place
-func shuffle(array []Tree) []Tree {
+func shuffle(array []Tree, r *rand.Rand) []Tree {
 	for i := range array { //run the loop till the range of array
-		j := rand.Intn(i + 1)                   //choose any random number
+		j := r.Intn(i + 1)                      //choose any random number
 		array[i], array[j] = array[j], array[i] //swap the random element with current element
 	}
 	return array
 }
-
@@ -0,0 +1,106 @@
+/*
+Contains the errors of the server, answered as JSON with the HTTP status they call for.
+*/
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// Codes of the errors, telling clients what went wrong without reading the message
+const (
+	codeBadRequest   = "bad_request"
+	codeNotFound     = "not_found"
+	codeNotAllowed   = "method_not_allowed"
+	codeUnauthorized = "unauthorized"
+	codeIllegalMove  = "illegal_move"
+	codeGameOver     = "game_over"
+	codeOutOfTime    = "out_of_time"
+	// codeTakebackRefused when no takebacks are left or fewer moves were played
+	codeTakebackRefused = "takeback_refused"
+	// codeInvalidPosition when a position sent by the client cannot be played
+	codeInvalidPosition = "invalid_position"
+	// codeNotRecorded when a past position is needed from a game saved without its start
+	codeNotRecorded = "not_recorded"
+	// codeForbidden when the token of a seat is missing or wrong
+	codeForbidden = "forbidden"
+	// codeNotYourTurn and codeWaiting for moves sent by a player out of turn, or before both seats are taken
+	codeNotYourTurn = "not_your_turn"
+	codeWaiting     = "waiting_for_opponent"
+	// codeNoAssistant when the engine cannot help in a game between two players
+	codeNoAssistant = "assistant_unavailable"
+	codeInternal    = "internal"
+)
+
+// ErrorResponseBody is the answer to a request that failed
+type ErrorResponseBody struct {
+	Error ErrorBody `json:"Error"`
+}
+
+// ErrorBody describes what went wrong
+type ErrorBody struct {
+	Code    string        `json:"Code"`
+	Message string        `json:"Message"`
+	Details *ErrorDetails `json:"Details,omitempty"`
+}
+
+// ErrorDetails of an illegal move: the square it is from and the squares the piece there can go to
+type ErrorDetails struct {
+	Square       string   `json:"Square,omitempty"`
+	LegalTargets []string `json:"LegalTargets,omitempty"`
+}
+
+// gameError is an error of a request, answered with the HTTP status
+type gameError struct {
+	status  int
+	code    string
+	message string
+	details *ErrorDetails
+}
+
+func (err *gameError) Error() string {
+	return err.message
+}
+
+// badRequest is the error of a request the server cannot read
+func badRequest(message string) *gameError {
+	return &gameError{status: http.StatusBadRequest, code: codeBadRequest, message: message}
+}
+
+// errGameNotFound answers requests on games missing from the store
+var errGameNotFound = &gameError{status: http.StatusNotFound, code: codeNotFound, message: "Game not found"}
+
+// writeError answers the request with the error, as an internal error unless it is a gameError
+func writeError(w http.ResponseWriter, err error) {
+	var gameErr *gameError
+	if !errors.As(err, &gameErr) {
+		gameErr = &gameError{status: http.StatusInternalServerError, code: codeInternal, message: err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(gameErr.status)
+	json.NewEncoder(w).Encode(ErrorResponseBody{ErrorBody{Code: gameErr.code, Message: gameErr.message, Details: gameErr.details}})
+}
+
+// decodeBody reads the JSON body of the request, rejecting malformed JSON and unknown fields.
+// An optional body may be left empty.
+func decodeBody(r *http.Request, v any, optional bool) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(v)
+	if errors.Is(err, io.EOF) {
+		if optional {
+			return nil
+		}
+		return badRequest("Missing request body")
+	}
+	if err != nil {
+		return badRequest("Malformed request body: " + err.Error())
+	}
+	if decoder.More() {
+		return badRequest("Malformed request body: more than one JSON value")
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+/*
+Contains the events of games, streamed to their clients with Server-Sent Events.
+*/
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// eventBuffer is the number of events kept for a slow client, later events are dropped
+	eventBuffer = 64
+	// keepAlive is the time between comments sent on idle streams, so that proxies keep them open
+	keepAlive = 15 * time.Second
+)
+
+// Types of events
+const (
+	// searchEvent after every depth the engine searched for its move, with a SearchEventBody
+	searchEvent = "search"
+	// moveEvent after every move of either player, with a MoveEventBody
+	moveEvent = "move"
+	// clockEvent after every move of a timed game, with a ClocksBody
+	clockEvent = "clock"
+	// undoEvent after moves were taken back, with an UndoEventBody
+	undoEvent = "undo"
+	// endEvent when the game is over, with an EndEventBody
+	endEvent = "end"
+)
+
+// SearchEventBody is the best line the engine found so far
+type SearchEventBody struct {
+	Depth int `json:"Depth"`
+	// Score in pawns for the engine
+	Score float64  `json:"Score"`
+	PV    []string `json:"PV"`
+}
+
+// MoveEventBody is a move played in the game
+type MoveEventBody struct {
+	// Player is user or engine, or white or black in games between two players
+	Player string `json:"Player"`
+	Move   string `json:"Move"`
+	SAN    string `json:"SAN"`
+	// FEN of the position after the move
+	FEN string `json:"FEN"`
+}
+
+// UndoEventBody is the position after moves were taken back
+type UndoEventBody struct {
+	FEN   string   `json:"FEN"`
+	Moves []string `json:"Moves"`
+}
+
+// EndEventBody tells how the game ended: checkmate, stalemate or timeout
+type EndEventBody struct {
+	Status string `json:"Status"`
+}
+
+// event sent to the clients of a game
+type event struct {
+	kind string
+	data any
+}
+
+// hub sends the events of a game to the clients following it. Its zero value is ready to use.
+type hub struct {
+	mutex       sync.Mutex
+	subscribers map[chan event]bool
+}
+
+// subscribe gives a channel receiving the events of the game until unsubscribe, or close
+func (h *hub) subscribe() chan event {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.subscribers == nil {
+		h.subscribers = make(map[chan event]bool)
+	}
+	events := make(chan event, eventBuffer)
+	h.subscribers[events] = true
+	return events
+}
+
+func (h *hub) unsubscribe(events chan event) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.subscribers[events] {
+		delete(h.subscribers, events)
+		close(events)
+	}
+}
+
+// publish sends the event to every client, without waiting for those that are behind
+func (h *hub) publish(kind string, data any) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for events := range h.subscribers {
+		select {
+		case events <- event{kind, data}:
+		default:
+		}
+	}
+}
+
+// close ends the streams of every client
+func (h *hub) close() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for events := range h.subscribers {
+		delete(h.subscribers, events)
+		close(events)
+	}
+}
+
+// gameEvents streams the events of the game until the client leaves or the game is removed
+func gameEvents(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+	game, ok := store.Get(r.PathValue("id"))
+	if !ok {
+		writeError(w, errGameNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, fmt.Errorf("Streaming is not supported"))
+		return
+	}
+	events := game.events.subscribe()
+	defer game.events.unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(e.data)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.kind, data)
+		}
+		flusher.Flush()
+	}
+}
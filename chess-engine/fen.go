@@ -0,0 +1,126 @@
+/*
+Contains conversion of boards from and to Forsyth-Edwards Notation (FEN).
+*/
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// startFEN is the position at the start of a game
+const startFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+// parseFEN reads the board and the player to move from a FEN position.
+// Castling rights, en passant and move counters are ignored as the engine does not use them.
+func parseFEN(fen string) (board Board, player Color, err error) {
+	fields := strings.Fields(fen)
+	if len(fields) == 0 {
+		return board, player, errors.New("Empty FEN")
+	}
+	rows := strings.Split(fields[0], "/")
+	if len(rows) != 8 {
+		return board, player, errors.New("FEN must have 8 ranks")
+	}
+	for i, row := range rows {
+		j := 0
+		for _, c := range row {
+			if c >= '1' && c <= '8' {
+				if j+int(c-'0') > 8 {
+					return board, player, errors.New("Invalid FEN rank " + row)
+				}
+				for n := 0; n < int(c-'0'); n++ {
+					board[i][j] = &Empty{}
+					j++
+				}
+				continue
+			}
+			piece := getPieceFromFEN(c)
+			if piece == nil || j >= 8 {
+				return board, player, errors.New("Invalid FEN rank " + row)
+			}
+			board[i][j] = piece
+			j++
+		}
+		if j != 8 {
+			return board, player, errors.New("Invalid FEN rank " + row)
+		}
+	}
+
+	player = User
+	if len(fields) > 1 {
+		switch fields[1] {
+		case "w":
+		case "b":
+			player = Self
+		default:
+			return board, player, errors.New("Invalid player to move " + fields[1])
+		}
+	}
+	return board, player, nil
+}
+
+// fen writes the board with player to move in FEN, without castling or en passant
+func (board Board) fen(player Color) string {
+	var sb strings.Builder
+	for i := 0; i < 8; i++ {
+		empty := 0
+		for j := 0; j < 8; j++ {
+			if board[i][j].getPlayer() == Undefined {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteString(strconv.Itoa(empty))
+				empty = 0
+			}
+			sb.WriteRune(getFENFromPiece(board[i][j]))
+		}
+		if empty > 0 {
+			sb.WriteString(strconv.Itoa(empty))
+		}
+		if i < 7 {
+			sb.WriteByte('/')
+		}
+	}
+	if player == Self {
+		sb.WriteString(" b")
+	} else {
+		sb.WriteString(" w")
+	}
+	sb.WriteString(" - - 0 1")
+	return sb.String()
+}
+
+func getPieceFromFEN(c rune) Piece {
+	color := User
+	if unicode.IsLower(c) {
+		color = Self
+	}
+	switch unicode.ToUpper(c) {
+	case 'K':
+		return &King{color}
+	case 'Q':
+		return &Queen{color}
+	case 'R':
+		return &Rook{color}
+	case 'B':
+		return &Bishop{color}
+	case 'N':
+		return &Knight{color}
+	case 'P':
+		return &Pawn{color}
+	default:
+		return nil
+	}
+}
+
+func getFENFromPiece(piece Piece) rune {
+	c := rune(strings.TrimSuffix(piece.String(), "'")[0])
+	if piece.getPlayer() == Self {
+		return unicode.ToLower(c)
+	}
+	return c
+}
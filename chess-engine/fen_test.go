@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestFENRoundTrip(t *testing.T) {
+	for _, fen := range []string{
+		// castling rights are not written, the engine does not castle
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w - - 0 1",
+		"7k/6pp/8/8/8/8/6PP/R6K w - - 0 1",
+		"rnb1kbnr/pppp1ppp/4p3/6q1/8/5PP1/PPPPP2P/RNBQKBNR w - - 0 1",
+		"k7/8/1Q6/8/8/8/8/2K5 b - - 0 1",
+	} {
+		board, player, err := parseFEN(fen)
+		if err != nil {
+			t.Errorf("%s: %v", fen, err)
+			continue
+		}
+		if got := board.fen(player); got != fen {
+			t.Errorf("got %s, want %s", got, fen)
+		}
+	}
+}
+
+func TestInvalidFEN(t *testing.T) {
+	for _, fen := range []string{
+		"",
+		"8/8/8/8/8/8/8 w - - 0 1",
+		"444/8/8/8/8/8/8/8 w - - 0 1",
+		"9/8/8/8/8/8/8/8 w - - 0 1",
+		"7/8/8/8/8/8/8/8 w - - 0 1",
+		"kkkkkkkkk/8/8/8/8/8/8/8 w - - 0 1",
+		"7x/8/8/8/8/8/8/8 w - - 0 1",
+		"8/8/8/8/8/8/8/8 x - - 0 1",
+	} {
+		if _, _, err := parseFEN(fen); err == nil {
+			t.Errorf("%q was accepted", fen)
+		}
+	}
+}
@@ -0,0 +1,545 @@
+/*
+Contains the games played on the server against the engine.
+*/
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultTakebacks is the number of moves a user can take back in a game, unless set otherwise
+const defaultTakebacks = 3
+
+// pondering makes the engine search on the user's time
+var pondering bool
+
+// personalities of the engine games can choose from, by name
+var personalities = map[string]*Weights{}
+
+// Game played on the server against the engine, or between two players. The engine always plays
+// black on its board: when it plays white, its board is the board of the game mirrored.
+type Game struct {
+	// mutex keeps requests on the game from running at the same time
+	mutex sync.Mutex
+	// Board of the engine and the player to move on it
+	Board  Board
+	ToMove Color
+	// Start is the position the game started from in FEN, on the board of the engine,
+	// and Moves the moves played since
+	Start string
+	Moves []Move
+	// EngineWhite mirrors the board of the game for the engine playing white
+	EngineWhite bool
+	// Takebacks left to the user, no limit when negative
+	Takebacks int
+	// Seats of a game between two players, nil in games against the engine
+	Seats *Seats
+	// ponder searches for the reply to the move expected from the user
+	ponder *ponder
+	// events of the game, sent to the clients following it
+	events hub
+	// UserClock and EngineClock of a timed game, nil otherwise. In games between two players,
+	// they are the clocks of white and black, given as White and Black in ClocksBody.
+	UserClock   *Clock
+	EngineClock *Clock
+	// turnStart is when the player to move started thinking
+	turnStart time.Time
+	// Level of the engine, full strength when nil
+	Level *Level
+	// Weights of the evaluation of the engine, from the personality chosen for the game
+	Weights     *Weights
+	Personality string
+}
+
+// GameOptions chosen when a game is created
+type GameOptions struct {
+	// Opponent is engine, by default, or human for a game between two players
+	Opponent string `json:"Opponent"`
+	// Assistant lets the engine analyse a game between two players once it is over
+	Assistant bool `json:"Assistant"`
+	// EngineColor is white or black, black by default
+	EngineColor string `json:"EngineColor"`
+	// Level of the engine, full strength when empty
+	Level       string `json:"Level"`
+	Personality string `json:"Personality"`
+	// FEN of the position to start from, the usual start position when empty
+	FEN string `json:"FEN"`
+	// Time on the clocks of a timed game and increment after every move, in seconds
+	Time      int `json:"Time"`
+	Increment int `json:"Increment"`
+	// Takebacks allowed to the user, defaultTakebacks when not set and no limit when negative
+	Takebacks *int `json:"Takebacks"`
+}
+
+// ClocksBody has the time left to each player in timed games, in milliseconds: to the user and
+// the engine in games against the engine, to white and black in games between two players
+type ClocksBody struct {
+	User   *int64 `json:"User,omitempty"`
+	Engine *int64 `json:"Engine,omitempty"`
+	White  *int64 `json:"White,omitempty"`
+	Black  *int64 `json:"Black,omitempty"`
+}
+
+// Status of games
+const (
+	playing   = "playing"
+	checkmate = "checkmate"
+	stalemate = "stalemate"
+	// timeout when the player to move ran out of time
+	timeout = "timeout"
+)
+
+// newGame starts a game with the options. The engine moves first when it is its turn.
+func newGame(options GameOptions) (*Game, error) {
+	game := &Game{Takebacks: defaultTakebacks}
+	if options.Takebacks != nil {
+		game.Takebacks = *options.Takebacks
+	}
+	switch strings.ToLower(options.Opponent) {
+	case "", "engine":
+	case "human":
+		if options.EngineColor != "" || options.Level != "" || options.Personality != "" {
+			return nil, badRequest("EngineColor, Level and Personality are for games against the engine")
+		}
+		game.Seats, game.Takebacks = newSeats(options.Assistant), 0
+	default:
+		return nil, badRequest("Unknown opponent " + options.Opponent)
+	}
+	switch strings.ToLower(options.EngineColor) {
+	case "", "black":
+	case "white":
+		game.EngineWhite = true
+	default:
+		return nil, badRequest("Unknown color " + options.EngineColor)
+	}
+
+	fen := options.FEN
+	if fen == "" {
+		fen = startFEN
+	}
+	board, player, err := parseFEN(fen)
+	if err != nil {
+		return nil, badRequest(err.Error())
+	}
+	if err := checkPosition(board, player); err != nil {
+		return nil, err
+	}
+	if game.EngineWhite {
+		board, player = board.mirror(), opponent(player)
+	}
+	game.Board, game.ToMove, game.Start = board, player, board.fen(player)
+
+	if options.Level != "" {
+		if game.Level, err = getLevel(options.Level); err != nil {
+			return nil, badRequest(err.Error())
+		}
+	}
+	if options.Personality != "" {
+		weights, ok := personalities[options.Personality]
+		if !ok {
+			return nil, badRequest("Unknown personality " + options.Personality)
+		}
+		game.Weights, game.Personality = weights, options.Personality
+	}
+	if options.Time > 0 {
+		game.UserClock = &Clock{Remaining: time.Duration(options.Time) * time.Second, Increment: time.Duration(options.Increment) * time.Second}
+		engineClock := *game.UserClock
+		game.EngineClock = &engineClock
+	}
+	game.turnStart = time.Now()
+
+	if game.ToMove == Self && game.Seats == nil {
+		game.reply()
+	}
+	return game, nil
+}
+
+// play makes the move of the player to move, on the board of the engine, and the reply of the engine
+// unless the game is over or played between two players
+func (game *Game) play(move Move) error {
+	status := game.status()
+	if status == timeout && game.clock(game.ToMove).Remaining > 0 {
+		// the clock ran out since the last request of the player
+		return game.flag()
+	}
+	if status != playing || (game.Seats == nil && game.ToMove != User) {
+		return &gameError{status: http.StatusConflict, code: codeGameOver, message: "The game is over, by " + status}
+	}
+	player := game.ToMove
+	if !onBoard(move.From) || !onBoard(move.To) {
+		return badRequest("Squares must be on the board")
+	}
+	square := &ErrorDetails{Square: game.orient(move.From).String()}
+	piece := game.Board[move.From.row][move.From.col]
+	if piece.getPlayer() != player {
+		fmt.Println("No element found at ", move.From)
+		return &gameError{http.StatusUnprocessableEntity, codeIllegalMove, "None of your pieces is on " + square.Square, square}
+	}
+	targets := game.legalTargets(player, move.From)
+	if !contains(targets, move.To) {
+		fmt.Println("Not a valid move for element at position:", move.From)
+		message := "Not a valid move"
+		if moves, _ := piece.getAllMoves(game.Board, move.From); contains(moves, move.To) {
+			message = "Cannot move here, King will be in CHECK state"
+		}
+		for _, target := range targets {
+			square.LegalTargets = append(square.LegalTargets, game.orient(target).String())
+		}
+		return &gameError{http.StatusUnprocessableEntity, codeIllegalMove, message, square}
+	}
+
+	if clock := game.clock(player); clock != nil {
+		thought := time.Since(game.turnStart)
+		if thought > clock.Remaining {
+			return game.flag()
+		}
+		clock.Remaining += clock.Increment - thought
+	}
+	game.turnStart = time.Now()
+	game.record(move)
+	if game.Seats == nil {
+		game.reply()
+	}
+	return nil
+}
+
+// flag ends the game on the clock of the player to move
+func (game *Game) flag() error {
+	game.clock(game.ToMove).Remaining = 0
+	game.events.publish(endEvent, EndEventBody{timeout})
+	return &gameError{status: http.StatusConflict, code: codeOutOfTime, message: "You ran out of time"}
+}
+
+// reply makes the move of the engine, unless it has none, and ponders on the move expected from the user
+func (game *Game) reply() {
+	if len(game.Board.generateNodes(Self)) == 0 {
+		fmt.Println("Looks like no moves left for me !")
+		return
+	}
+	engineStart := time.Now()
+
+	var lines []Line
+	if game.ponder != nil && game.ponder.move == game.Moves[len(game.Moves)-1] {
+		fmt.Println("Just as I thought...")
+		lines = game.ponder.hit()
+	} else {
+		if game.ponder != nil {
+			game.ponder.miss()
+		}
+		fmt.Println("Hmm....nice move....you have forced me to hit my nerves...")
+		options := game.searchOptions()
+		options.Info = func(rank int, line Line) {
+			if rank == 1 {
+				game.events.publish(searchEvent, SearchEventBody{line.Depth, line.Score, game.moveStrings(line.Moves)})
+			}
+		}
+		lines = analyse(game.Board, Self, options)
+	}
+	game.ponder = nil
+	oldPos, newPos, score := bestMove(lines, Self, game.Level, pickRand(game.searchOptions()))
+	fmt.Println(oldPos, newPos, score)
+	// a search stopped before it found a move gives none, which must not be played
+	if oldPos == newPos {
+		fmt.Println("Looks like no moves left for me !")
+		return
+	}
+
+	// the engine loses on time like the user, its move is not played
+	if clock := game.EngineClock; clock != nil {
+		thought := time.Since(engineStart)
+		if thought > clock.Remaining {
+			fmt.Println("Looks like I ran out of time !")
+			clock.Remaining = 0
+			game.events.publish(endEvent, EndEventBody{timeout})
+			return
+		}
+		clock.Remaining += clock.Increment - thought
+	}
+	game.turnStart = time.Now()
+	game.record(Move{oldPos, newPos})
+	if move, ok := expectedMove(lines); ok && pondering && game.status() == playing {
+		// in timed games the search deepens without limit, until the user would run out of time
+		var limit time.Duration
+		if game.UserClock != nil {
+			limit = game.UserClock.Remaining
+		}
+		game.ponder = startPonder(game.Board, Self, move, game.searchOptions(), limit)
+	}
+}
+
+// undo takes back the last plies, replaying the game from its start, and lets the engine move
+// again when that leaves it to move
+func (game *Game) undo(plies int) error {
+	if game.Seats != nil {
+		return &gameError{status: http.StatusConflict, code: codeTakebackRefused, message: "Moves cannot be taken back in games between two players"}
+	}
+	if plies < 1 {
+		return badRequest("At least one move must be taken back")
+	}
+	if game.Takebacks == 0 {
+		return &gameError{status: http.StatusConflict, code: codeTakebackRefused, message: "No takebacks left"}
+	}
+	if plies > len(game.Moves) {
+		return &gameError{status: http.StatusConflict, code: codeTakebackRefused,
+			message: fmt.Sprintf("Cannot take back %d moves, %d were played", plies, len(game.Moves))}
+	}
+	kept := len(game.Moves) - plies
+	board, player, err := game.positionAt(kept)
+	if err != nil {
+		return err
+	}
+	if game.ponder != nil {
+		game.ponder.miss()
+		game.ponder = nil
+	}
+	game.Board, game.ToMove, game.Moves = board, player, game.Moves[:kept]
+	if game.Takebacks > 0 {
+		game.Takebacks--
+	}
+	game.turnStart = time.Now()
+	game.events.publish(undoEvent, UndoEventBody{game.fen(), game.moveStrings(game.Moves)})
+	if game.ToMove == Self {
+		game.reply()
+	}
+	return nil
+}
+
+// positionAt gives the board of the engine and the player to move after the first plies of the game
+func (game *Game) positionAt(plies int) (Board, Color, error) {
+	if game.Start == "" {
+		return Board{}, Undefined, &gameError{status: http.StatusConflict, code: codeNotRecorded, message: "The start of the game was not recorded"}
+	}
+	board, player, err := parseFEN(game.Start)
+	if err != nil {
+		return board, player, err
+	}
+	for _, move := range game.Moves[:plies] {
+		board.makeMove(move.From, move.To)
+		player = opponent(player)
+	}
+	return board, player, nil
+}
+
+// record plays the move of the player to move, like push, and tells the clients of the game
+func (game *Game) record(move Move) {
+	board, _ := game.view()
+	player := game.playerName(game.ToMove)
+	san := board.san(game.orientColor(game.ToMove), game.orientMove(move))
+	game.push(move)
+
+	game.events.publish(moveEvent, MoveEventBody{player, game.orientMove(move).String(), san, game.fen()})
+	if game.UserClock != nil {
+		game.events.publish(clockEvent, game.clocks())
+	}
+	if status := game.status(); status != playing {
+		game.events.publish(endEvent, EndEventBody{status})
+	}
+}
+
+// push makes the move of the player to move and records it
+func (game *Game) push(move Move) {
+	game.Board.makeMove(move.From, move.To)
+	game.Moves = append(game.Moves, move)
+	game.ToMove = opponent(game.ToMove)
+}
+
+// status of the game: playing, checkmate, stalemate or timeout
+func (game *Game) status() string {
+	if clock := game.clock(game.ToMove); clock != nil && clock.Remaining-game.thinking() <= 0 {
+		return timeout
+	}
+	if len(game.Board.generateNodes(game.ToMove)) > 0 {
+		return playing
+	}
+	if game.Board.check(game.ToMove) == 1 {
+		return checkmate
+	}
+	return stalemate
+}
+
+// legalTargets gives the squares the piece of player at from can move to
+func (game *Game) legalTargets(player Color, from Position) (targets []Position) {
+	for _, node := range game.Board.generateNodes(player) {
+		if node.oldPos == from {
+			targets = append(targets, node.newPos)
+		}
+	}
+	return
+}
+
+// searchOptions for the engine's move, limited by its clock in timed games
+func (game *Game) searchOptions() SearchOptions {
+	options := engineOptions
+	options.Level = game.Level
+	if game.Weights != nil {
+		options.Evaluator = game.Weights
+	}
+	if game.EngineClock != nil {
+		options.Depth = MaxSearchDepth
+		options.Time = newTimeManager(*game.EngineClock)
+	}
+	return options
+}
+
+// clock of the player, nil in untimed games
+func (game *Game) clock(player Color) *Clock {
+	if player == User {
+		return game.UserClock
+	}
+	return game.EngineClock
+}
+
+// clocks gives the time left to both players while the player to move thinks, nil in untimed games.
+// The clock of the engine is stopped while it is not thinking on a request.
+func (game *Game) clocks() *ClocksBody {
+	if game.UserClock == nil {
+		return nil
+	}
+	remaining := [2]time.Duration{User: game.UserClock.Remaining, Self: game.EngineClock.Remaining}
+	if status := game.status(); status == playing || status == timeout {
+		remaining[game.ToMove] = max(remaining[game.ToMove]-game.thinking(), 0)
+	}
+	user, engine := remaining[User].Milliseconds(), remaining[Self].Milliseconds()
+	if game.Seats != nil {
+		return &ClocksBody{White: &user, Black: &engine}
+	}
+	return &ClocksBody{User: &user, Engine: &engine}
+}
+
+// thinking gives the time the player to move has spent on its move, while its clock runs: that of the
+// user against the engine, whose own clock only runs on a request, and either one once both players joined
+func (game *Game) thinking() time.Duration {
+	if game.UserClock == nil || game.Seats == nil && game.ToMove != User || game.Seats != nil && !game.Seats.full() {
+		return 0
+	}
+	return time.Since(game.turnStart)
+}
+
+// close stops the search running for the game on the user's time, once it is removed from the store
+func (game *Game) close() {
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	if game.ponder != nil {
+		game.ponder.miss()
+		game.ponder = nil
+	}
+	game.events.close()
+}
+
+// orient turns a position between the board of the game and the board of the engine
+func (game *Game) orient(position Position) Position {
+	if game.EngineWhite {
+		return position.mirror()
+	}
+	return position
+}
+
+// orientColor turns a color between the board of the game and the board of the engine
+func (game *Game) orientColor(color Color) Color {
+	if game.EngineWhite {
+		return opponent(color)
+	}
+	return color
+}
+
+// orientMove turns a move between the board of the game and the board of the engine
+func (game *Game) orientMove(move Move) Move {
+	return Move{game.orient(move.From), game.orient(move.To)}
+}
+
+// moveStrings gives the moves on the board of the engine in coordinate notation on the board of the game
+func (game *Game) moveStrings(moves []Move) []string {
+	strs := []string{}
+	for _, move := range moves {
+		strs = append(strs, game.orientMove(move).String())
+	}
+	return strs
+}
+
+// view gives the board of the game, mirrored back when the engine plays white, and the color of the user on it
+func (game *Game) view() (Board, Color) {
+	if game.EngineWhite {
+		return game.Board.mirror(), opponent(User)
+	}
+	return game.Board, User
+}
+
+// fen gives the position of the game in FEN
+func (game *Game) fen() string {
+	if game.EngineWhite {
+		return game.Board.mirror().fen(opponent(game.ToMove))
+	}
+	return game.Board.fen(game.ToMove)
+}
+
+// engineColor gives the color the engine plays in the game, none in games between two players
+func (game *Game) engineColor() string {
+	if game.Seats != nil {
+		return ""
+	}
+	if game.EngineWhite {
+		return "white"
+	}
+	return "black"
+}
+
+// playerName gives who plays the color on the board of the engine: user or engine,
+// or white or black in games between two players
+func (game *Game) playerName(color Color) string {
+	if game.Seats != nil {
+		return colorName(color)
+	}
+	if color == User {
+		return "user"
+	}
+	return "engine"
+}
+
+// assistance tells why the engine cannot help with the game, nil when it can. In games between two
+// players, it only helps when the game is over and the players asked for it.
+func (game *Game) assistance() error {
+	if game.Seats == nil {
+		return nil
+	}
+	if !game.Seats.Assistant {
+		return &gameError{status: http.StatusConflict, code: codeNoAssistant, message: "The game was created without the assistant"}
+	}
+	if status := game.status(); status == playing {
+		return &gameError{status: http.StatusConflict, code: codeNoAssistant, message: "The engine helps once the game is over"}
+	}
+	return nil
+}
+
+// checkPosition rejects positions the engine cannot play: each side needs exactly one king, the king of
+// the player who just moved cannot be in check, and no pawn stands on the first or last rank
+func checkPosition(board Board, player Color) error {
+	invalid := func(message string) error {
+		return &gameError{status: http.StatusUnprocessableEntity, code: codeInvalidPosition, message: "Invalid position, " + message}
+	}
+	for _, color := range []Color{White, Black} {
+		if board.countTypeOfPiece(reflect.TypeOf(&King{}), color) != 1 {
+			return invalid(colorName(color) + " needs exactly one king")
+		}
+	}
+	if board.check(opponent(player)) == 1 {
+		return invalid("the king of " + colorName(opponent(player)) + " can be captured")
+	}
+	for _, row := range []int{0, 7} {
+		for col := 0; col < 8; col++ {
+			if _, ok := board[row][col].(*Pawn); ok {
+				return invalid("a pawn is on the first or last rank")
+			}
+		}
+	}
+	return nil
+}
+
+// onBoard tells if the position is one of the 64 squares
+func onBoard(position Position) bool {
+	return position.row >= 0 && position.row < 8 && position.col >= 0 && position.col < 8
+}
@@ -0,0 +1,240 @@
+/*
+Contains the endpoints of the server outside the versioned API, and what all its endpoints share.
+*/
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// adminToken authorises the requests of the admin endpoints, which are off when it is empty
+var adminToken string
+
+// AnalysisLine is one of the best lines found for the player to move
+type AnalysisLine struct {
+	Moves []string `json:"Moves"`
+	// Score in pawns for the player to move
+	Score float64 `json:"Score"`
+	Depth int     `json:"Depth"`
+}
+
+// AnalysisResponseBody sent with the best lines of a game
+type AnalysisResponseBody struct {
+	Lines []AnalysisLine `json:"Lines"`
+}
+
+// expireGames removes the games without activity for the ttl from the store, every interval
+func expireGames(ttl time.Duration, interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := store.Expire(time.Now().Add(-ttl)); err != nil {
+			log.Println("Could not expire games", err)
+		}
+	}
+}
+
+// analysis finds the best lines for the user in the game, or the player to move between two players,
+// as many as the multipv parameter (1 by default, maxAnalyseMultiPV at most)
+func analysis(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+	w.Header().Set("Content-Type", "application/json")
+	id := r.URL.Query().Get("id")
+	game, ok := store.Get(id)
+	if !ok {
+		writeError(w, errGameNotFound)
+		return
+	}
+
+	options := engineOptions
+	if multiPV, err := strconv.Atoi(r.URL.Query().Get("multipv")); err == nil {
+		options.MultiPV = min(multiPV, maxAnalyseMultiPV)
+	}
+	if depth, err := strconv.Atoi(r.URL.Query().Get("depth")); err == nil && depth <= MaxDepth {
+		options.Depth = depth
+	}
+
+	// the game is not locked while searching, so that the analysis does not hold up other requests
+	game.mutex.Lock()
+	if err := game.assistance(); err != nil {
+		game.mutex.Unlock()
+		writeError(w, err)
+		return
+	}
+	// the lines of a game between two players are for the player to move
+	board, player := game.Board, User
+	if game.Seats != nil {
+		player = game.ToMove
+	}
+	game.mutex.Unlock()
+
+	var res AnalysisResponseBody
+	for _, line := range analyse(board, player, options) {
+		res.Lines = append(res.Lines, AnalysisLine{
+			Moves: game.moveStrings(line.Moves),
+			Score: line.scoreFor(player),
+			Depth: line.Depth,
+		})
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res)
+}
+
+// AttacksResponseBody has the squares attacked by the user and the engine, like e4
+type AttacksResponseBody struct {
+	User   []string `json:"User"`
+	Engine []string `json:"Engine"`
+}
+
+// attacked gives the squares under attack by each side in the game, to show over the board,
+// in games between two players only once the game is over
+func attacked(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+	w.Header().Set("Content-Type", "application/json")
+	game, ok := store.Get(r.PathValue("id"))
+	if !ok {
+		writeError(w, errGameNotFound)
+		return
+	}
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	if err := game.assistance(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	res := AttacksResponseBody{User: []string{}, Engine: []string{}}
+	for _, position := range getPositions(game.Board.attackMap(User)) {
+		res.User = append(res.User, game.orient(position).String())
+	}
+	for _, position := range getPositions(game.Board.attackMap(Self)) {
+		res.Engine = append(res.Engine, game.orient(position).String())
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res)
+}
+
+// evaluation breaks the evaluation of the board of the game down by term, in games between
+// two players only once the game is over
+func evaluation(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+	w.Header().Set("Content-Type", "application/json")
+	game, ok := store.Get(r.PathValue("id"))
+	if !ok {
+		writeError(w, errGameNotFound)
+		return
+	}
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	if err := game.assistance(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	weights := game.Weights
+	if weights == nil {
+		weights = engineWeights()
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(game.Board.trace(weights))
+}
+
+// admin checks the admin token of the request, answering it when it is missing or wrong
+func admin(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get("Authorization") != "Bearer "+adminToken {
+		writeError(w, &gameError{status: http.StatusUnauthorized, code: codeUnauthorized, message: "Unauthorized"})
+		return false
+	}
+	return true
+}
+
+// listGames gives the games of the store, most recently active first
+func listGames(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !admin(w, r) {
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(store.List())
+}
+
+// deleteGame removes a game from the store, games between two players too
+func deleteGame(w http.ResponseWriter, r *http.Request) {
+	if !admin(w, r) {
+		return
+	}
+	dropGame(w, r.PathValue("id"))
+}
+
+// enableCors lets the web page call the server from another origin
+func enableCors(w *http.ResponseWriter) {
+	(*w).Header().Set("Access-Control-Allow-Origin", "*")
+	(*w).Header().Set("Access-Control-Allow-Headers",
+		"Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+}
+
+// getAsSlice gives the board as rows of pieces, like the answers of the server
+func (board Board) getAsSlice() (boardList [][]string) {
+	for i := 0; i < len(board); i++ {
+		rowList := []string{}
+		for j := 0; j < len(board[i]); j++ {
+			rowList = append(rowList, board[i][j].String())
+		}
+		boardList = append(boardList, rowList)
+
+	}
+	return
+}
+
+// formBoardUsingSlice makes the board from rows of pieces, like the ones of getAsSlice
+func formBoardUsingSlice(boardList [][]string) (board Board) {
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			board[i][j] = &Empty{}
+		}
+	}
+	for i := 0; i < len(boardList); i++ {
+		for j := 0; j < len(boardList[i]); j++ {
+			if boardList[i][j] != (&Empty{}).String() {
+				board[i][j] = getPieceFromString(boardList[i][j])
+			}
+		}
+
+	}
+	return
+}
+
+// getPieceFromString gives the piece written like in getAsSlice, Empty when unknown
+func getPieceFromString(pStr string) Piece {
+	switch pStr {
+	case "K":
+		return &King{User}
+	case "K'":
+		return &King{Self}
+	case "Q":
+		return &Queen{User}
+	case "Q'":
+		return &Queen{Self}
+	case "B":
+		return &Bishop{User}
+	case "B'":
+		return &Bishop{Self}
+	case "N":
+		return &Knight{User}
+	case "N'":
+		return &Knight{Self}
+	case "P":
+		return &Pawn{User}
+	case "P'":
+		return &Pawn{Self}
+	case "R":
+		return &Rook{User}
+	case "R'":
+		return &Rook{Self}
+
+	default:
+		return &Empty{}
+	}
+}
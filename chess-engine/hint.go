@@ -0,0 +1,46 @@
+/*
+Contains hints, the moves suggested to a player after a short search.
+*/
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// hintTime is the time searched for a hint
+const hintTime = 500 * time.Millisecond
+
+// Hint is the move suggested to a player
+type Hint struct {
+	Move Move
+	SAN  string
+	// Score of the line the move starts, in pawns for the player
+	Score float64
+	Depth int
+}
+
+// hint searches the best move of player on board for moveTime at full strength, false when player has no move
+func hint(board Board, player Color, options SearchOptions, moveTime time.Duration) (Hint, bool) {
+	options.Depth = MaxSearchDepth
+	options.Time = fixedTimeManager(moveTime)
+	options.Level, options.MultiPV, options.Info, options.Stop = nil, 1, nil, nil
+	lines := analyse(board, player, options)
+	if len(lines) == 0 || len(lines[0].Moves) == 0 {
+		return Hint{}, false
+	}
+	move := lines[0].Moves[0]
+	return Hint{Move: move, SAN: board.san(player, move), Score: lines[0].scoreFor(player), Depth: lines[0].Depth}, true
+}
+
+// summary gives the hint in one line, like "Nf3 +0.35 at depth 4"
+func (hint Hint) summary() string {
+	score := fmt.Sprintf("%+.2f", hint.Score)
+	switch hint.Score {
+	case MAX:
+		score = "mating"
+	case MIN:
+		score = "getting mated"
+	}
+	return fmt.Sprintf("%s %s at depth %d", hint.SAN, score, hint.Depth)
+}
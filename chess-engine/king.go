@@ -0,0 +1,72 @@
+/*
+Contains the evaluation of the safety of the kings.
+*/
+package main
+
+import (
+	"math/bits"
+)
+
+// getKingSafetyScore scores how safe the king of Self is compared to the user's, in pawns
+func (board Board) getKingSafetyScore(weights *Weights, phase int) float64 {
+	return taper(board.getKingSafety(weights, Self)-board.getKingSafety(weights, User), 0, phase)
+}
+
+// getKingSafety scores the shelter of the king of player against the pieces and pawns attacking it
+func (board Board) getKingSafety(weights *Weights, player Color) float64 {
+	king, err := board.findPiece(King{player})
+	if err != nil {
+		return 0
+	}
+	enemy := opponent(player)
+	forward := getForward(player)
+	score := 0.0
+
+	for col := king.col - 1; col <= king.col+1; col++ {
+		if col < 0 || col > 7 {
+			continue
+		}
+		for distance := 1; distance < len(weights.Shield); distance++ {
+			if board.isPawn(king.row+distance*forward, col, player) {
+				score += weights.Shield[distance]
+			}
+		}
+		for distance := 1; distance < len(weights.Storm); distance++ {
+			if board.isPawn(king.row+distance*forward, col, enemy) {
+				score -= weights.Storm[distance]
+			}
+		}
+		if !board.hasPawnOnFile(col, player, 0, 7) {
+			if board.hasPawnOnFile(col, enemy, 0, 7) {
+				score -= weights.SemiOpenFile
+			} else {
+				score -= weights.OpenFile
+			}
+		}
+	}
+
+	// attacks on the king and the squares around it
+	zone := board.stepAttacks(king, kingSteps[:]) | 1<<(king.row*8+king.col)
+	attackers, attacks := 0, 0.0
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			index := board[i][j].Index()
+			if board[i][j].getPlayer() != enemy || weights.Attack[index%6] == 0 {
+				continue
+			}
+			if hits := bits.OnesCount64(board.attacks(Position{i, j}) & zone); hits > 0 {
+				attackers++
+				attacks += float64(hits) * weights.Attack[index%6]
+			}
+		}
+	}
+	if attackers >= len(weights.AttackersShare) {
+		attackers = len(weights.AttackersShare) - 1
+	}
+	score -= attacks * weights.AttackersShare[attackers]
+
+	// the more lines open to the king, the more exposed it is
+	virtualMobility := board.slideAttacks(king, rookDirections[:]) | board.slideAttacks(king, bishopDirections[:])
+	score -= float64(bits.OnesCount64(virtualMobility&^board.occupancy(player))) * weights.VirtualMobility
+	return score
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestKingSafety(t *testing.T) {
+	safety := func(fen string) float64 {
+		board, _, err := parseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return board.getKingSafety(defaultWeights, User)
+	}
+	for _, test := range []struct {
+		name         string
+		safer, other string
+	}{
+		{"shield next to the king", "k7/8/8/8/8/8/5PPP/6K1 w - - 0 1", "k7/8/8/8/8/5PPP/8/6K1 w - - 0 1"},
+		{"shield away from the king", "k7/8/8/8/8/5PPP/8/6K1 w - - 0 1", "k7/8/8/8/8/8/8/6K1 w - - 0 1"},
+		{"enemy pawns storming", "k7/8/8/8/8/8/5PPP/6K1 w - - 0 1", "k7/8/8/8/8/6p1/5PPP/6K1 w - - 0 1"},
+		{"two pieces attacking", "k7/8/8/8/7q/8/5PPP/6K1 w - - 0 1", "k7/8/8/8/7q/8/5PPP/r5K1 w - - 0 1"},
+	} {
+		if safer, other := safety(test.safer), safety(test.other); safer <= other {
+			t.Errorf("%s: got %v for %s, not more than %v for %s", test.name, safer, test.safer, other, test.other)
+		}
+	}
+	// a single piece attacking is no danger
+	if alone, none := safety("k7/8/8/8/7q/8/5PPP/6K1 w - - 0 1"), safety("k7/8/8/8/8/8/5PPP/6K1 w - - 0 1"); alone != none {
+		t.Errorf("got %v with a queen attacking alone, want %v", alone, none)
+	}
+}
+
+func TestKingSafetyOfStart(t *testing.T) {
+	board, _, _ := parseFEN(startFEN)
+	if score := board.getKingSafetyScore(defaultWeights, 0); score != 0 {
+		t.Errorf("got %v for the start position, want 0", score)
+	}
+}
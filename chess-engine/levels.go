@@ -0,0 +1,96 @@
+/*
+Contains the difficulty levels weakening the engine for weaker players.
+*/
+package main
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxSkill plays the best move found, lower skills may pick weaker ones
+	MaxSkill = 20
+	// skillLines are searched to pick a move from when the skill is below MaxSkill
+	skillLines = 4
+)
+
+// Level of play of the engine
+type Level struct {
+	Name string
+	// Depth of the MiniMax tree
+	Depth int
+	// Nodes searched at most for a move, no limit when 0
+	Nodes int
+	// Noise added to the evaluation of positions, in pawns
+	Noise float64
+	// Skill from 0 to MaxSkill, the lower the more often a weaker line is played
+	Skill int
+	// Elo is the nominal rating of the level, used for UCI_Elo.
+	// The calibrate command estimates the ratings of the levels relative to each other.
+	Elo int
+}
+
+// levels from the weakest to the strongest
+var levels = []Level{
+	{Name: "beginner", Depth: 1, Nodes: 200, Noise: 2, Skill: 0, Elo: 800},
+	{Name: "easy", Depth: 2, Nodes: 1000, Noise: 1, Skill: 5, Elo: 1100},
+	{Name: "medium", Depth: 3, Nodes: 5000, Noise: 0.5, Skill: 10, Elo: 1400},
+	{Name: "hard", Depth: 4, Nodes: 20000, Noise: 0.2, Skill: 15, Elo: 1700},
+	{Name: "expert", Depth: MaxDepth, Skill: MaxSkill, Elo: 2000},
+}
+
+// getLevel finds a level by its name
+func getLevel(name string) (*Level, error) {
+	for i := range levels {
+		if strings.EqualFold(levels[i].Name, name) {
+			return &levels[i], nil
+		}
+	}
+	return nil, errors.New("Unknown level " + name)
+}
+
+// getLevelForElo gives the strongest level not rated above elo, the weakest one below all ratings
+func getLevelForElo(elo int) *Level {
+	level := &levels[0]
+	for i := range levels {
+		if levels[i].Elo <= elo {
+			level = &levels[i]
+		}
+	}
+	return level
+}
+
+// pick chooses the line to play among the best lines of player, like the skill levels of Stockfish:
+// each line gets a random bonus that grows as the skill drops and with the spread of the scores,
+// so weaker levels play weaker moves more often, but rarely blunder when one move stands out.
+func (level *Level) pick(lines []Line, player Color, r *rand.Rand) Line {
+	if level.Skill >= MaxSkill || len(lines) < 2 {
+		return lines[0]
+	}
+	weakness := float64(120 - 2*level.Skill)
+	top := lines[0].scoreFor(player)
+	spread := math.Min(top-lines[len(lines)-1].scoreFor(player), 1)
+
+	best, bestScore := lines[0], math.Inf(-1)
+	for _, line := range lines {
+		score := line.scoreFor(player)
+		push := (weakness*(top-score) + spread*r.Float64()*weakness) / 128
+		if score+push > bestScore {
+			best, bestScore = line, score+push
+		}
+	}
+	return best
+}
+
+// pickRand gives the random numbers a level picks its line with, always the same ones in deterministic searches
+func pickRand(options SearchOptions) *rand.Rand {
+	seed := time.Now().UnixNano()
+	if options.Deterministic {
+		seed = 1
+	}
+	return rand.New(rand.NewSource(seed))
+}
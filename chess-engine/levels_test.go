@@ -0,0 +1,95 @@
+package main
+
+import "testing"
+
+func TestLevelCapsDepth(t *testing.T) {
+	board, player, _ := parseFEN(startFEN)
+	level := Level{Name: "test", Depth: 2, Skill: MaxSkill}
+	lines := analyse(board, player, SearchOptions{Depth: 4, Level: &level, Deterministic: true})
+	if lines[0].Depth != 2 {
+		t.Errorf("searched to depth %d, want 2", lines[0].Depth)
+	}
+}
+
+func TestLevelCapsNodes(t *testing.T) {
+	board, player, _ := parseFEN(startFEN)
+	// the first depth is searched in full, the second one needs more nodes than allowed
+	level := Level{Name: "test", Depth: MaxDepth, Nodes: 30, Skill: MaxSkill}
+	lines := analyse(board, player, SearchOptions{Level: &level, Deterministic: true})
+	if lines[0].Depth != 1 {
+		t.Errorf("searched to depth %d, want 1", lines[0].Depth)
+	}
+}
+
+func TestLevelSearchesLinesToPickFrom(t *testing.T) {
+	board, player, _ := parseFEN(startFEN)
+	level, _ := getLevel("beginner")
+	lines := analyse(board, player, SearchOptions{Level: level, Deterministic: true})
+	if len(lines) != skillLines {
+		t.Errorf("got %d lines, want %d", len(lines), skillLines)
+	}
+}
+
+func TestPickBestLine(t *testing.T) {
+	lines := []Line{
+		{Moves: []Move{{Position{1, 4}, Position{2, 4}}}, Score: 1},
+		{Moves: []Move{{Position{1, 3}, Position{2, 3}}}, Score: 0},
+	}
+	expert, _ := getLevel("expert")
+	beginner, _ := getLevel("beginner")
+	for _, test := range []struct {
+		level *Level
+		lines []Line
+	}{
+		{expert, lines},
+		{beginner, lines[:1]},
+	} {
+		for i := 0; i < 10; i++ {
+			if got := test.level.pick(test.lines, Self, pickRand(SearchOptions{})); got.Score != 1 {
+				t.Errorf("%s picked the line scored %v", test.level.Name, got.Score)
+			}
+		}
+	}
+}
+
+func TestPickDeterministic(t *testing.T) {
+	lines := []Line{{Score: 0.3}, {Score: 0.2}, {Score: 0.1}, {Score: 0}}
+	beginner, _ := getLevel("beginner")
+	first, second := pickRand(SearchOptions{Deterministic: true}), pickRand(SearchOptions{Deterministic: true})
+	for i := 0; i < 20; i++ {
+		if a, b := beginner.pick(lines, User, first), beginner.pick(lines, User, second); a.Score != b.Score {
+			t.Fatalf("pick %d: got lines scored %v and %v", i, a.Score, b.Score)
+		}
+	}
+
+	board, player, _ := parseFEN(startFEN)
+	options := SearchOptions{Level: beginner, Deterministic: true}
+	from, to, _ := search(board, player, options)
+	for i := 0; i < 3; i++ {
+		if again, againTo, _ := search(board, player, options); again != from || againTo != to {
+			t.Errorf("played %v %v, then %v %v", from, to, again, againTo)
+		}
+	}
+}
+
+func TestGetLevel(t *testing.T) {
+	for _, test := range []struct {
+		elo  int
+		name string
+	}{
+		{0, "beginner"},
+		{1100, "easy"},
+		{1500, "medium"},
+		{3000, "expert"},
+	} {
+		if got := getLevelForElo(test.elo).Name; got != test.name {
+			t.Errorf("elo %d: got %s, want %s", test.elo, got, test.name)
+		}
+	}
+	if level, err := getLevel("Hard"); err != nil || level.Name != "hard" {
+		t.Errorf("got %v, %v, want the hard level", level, err)
+	}
+	if _, err := getLevel("grandmaster"); err == nil {
+		t.Error("an unknown level was found")
+	}
+}
@@ -4,11 +4,78 @@ This is executable package which will run the chess engine.
 package main
 
 import (
+	"flag"
 	"fmt"
+	"math/rand"
+	"strings"
 )
 
 // overall goal - make attacking from defensive
 func main() {
+	flag.IntVar(&engineOptions.Threads, "threads", 1, "number of threads searching for a move")
+	flag.IntVar(&engineOptions.Depth, "depth", MaxDepth, "depth of the MiniMax tree")
+	levelName := flag.String("level", "", "difficulty level: beginner, easy, medium, hard or expert")
+	weightsPath := flag.String("weights", "", "JSON file with the weights of the evaluation")
+	networkPath := flag.String("network", "", "JSON file of a network evaluating positions instead of the weights")
+	takebacks := flag.Int("takebacks", defaultTakebacks, "moves the user can take back with undo, no limit when negative")
+	flag.Parse()
+
+	if *weightsPath != "" {
+		weights, err := loadWeights(*weightsPath)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		engineOptions.Evaluator = weights
+	}
+	if *networkPath != "" {
+		network, err := loadNetwork(*networkPath)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		engineOptions.Evaluator = network
+	}
+
+	if *levelName != "" {
+		level, err := getLevel(*levelName)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		engineOptions.Level = level
+	}
+
+	switch flag.Arg(0) {
+	case "bench":
+		bench(engineOptions.Depth, engineOptions.Threads)
+		return
+	case "analyse":
+		analyseCommand(flag.Args()[1:])
+		return
+	case "uci":
+		uci()
+		return
+	case "calibrate":
+		calibrateCommand(flag.Args()[1:])
+		return
+	case "weights":
+		weightsCommand(flag.Args()[1:])
+		return
+	case "tune":
+		tuneCommand(flag.Args()[1:])
+		return
+	case "train":
+		trainCommand(flag.Args()[1:])
+		return
+	case "eval":
+		evalCommand(flag.Args()[1:])
+		return
+	case "hint":
+		hintCommand(flag.Args()[1:])
+		return
+	}
+
 	board := Board{}
 	board.initialise()
 	// for i := 0; i < 8; i++ {
@@ -40,10 +107,32 @@ func main() {
 		score                          float64
 		allPos                         []Position
 		err                            error
+		// history has the boards before each move of the user, for undo
+		history []Board
 	)
 	for {
 		fmt.Printf("move from: ")
 		fmt.Scanf("%s \n", &from)
+		if from == "undo" {
+			if len(history) == 0 {
+				fmt.Println("No move to take back")
+				continue
+			}
+			if *takebacks == 0 {
+				fmt.Println("No takebacks left")
+				continue
+			}
+			board, history = history[len(history)-1], history[:len(history)-1]
+			*takebacks--
+			board.print()
+			continue
+		}
+		if from == "hint" {
+			if h, ok := hint(board, User, engineOptions, hintTime); ok {
+				fmt.Println("Try", h.Move, "-", h.summary())
+			}
+			continue
+		}
 		if !validateInput(from) {
 			fmt.Println("Invalid Input")
 			continue
@@ -72,11 +161,12 @@ func main() {
 			fmt.Println("You cannot move here, your king will be in check position")
 			continue
 		}
+		history = append(history, board)
 		board.makeMove(fromPos, toPos)
 		board.print()
 		//check for stalemate by generating all moves
 		fmt.Println("Hmm....nice move....you have forced me to hit my nerves...")
-		oldPos, newPos, score = miniMax(0, Tree{board: board}, Self, MIN, MAX)
+		oldPos, newPos, score = search(board, Self, engineOptions)
 		if score == MAX {
 			fmt.Println("Looks like no moves left for me !")
 			break
@@ -94,6 +184,146 @@ func main() {
 	}
 }
 
+// analyseCommand prints the best lines of the position given in FEN, the start position by default
+func analyseCommand(args []string) {
+	flags := flag.NewFlagSet("analyse", flag.ExitOnError)
+	multiPV := flags.Int("multipv", 3, "number of lines to show")
+	flags.Parse(args)
+
+	fen := startFEN
+	if flags.NArg() > 0 {
+		fen = strings.Join(flags.Args(), " ")
+	}
+	board, player, err := parseFEN(fen)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	board.print()
+
+	options := engineOptions
+	options.MultiPV = *multiPV
+	for i, line := range analyse(board, player, options) {
+		fmt.Printf("%d. %+.2f\t%s\n", i+1, line.scoreFor(player), strings.Join(moveStrings(line.Moves), " "))
+	}
+}
+
+// hintCommand suggests a move in the position given in FEN, the start position by default
+func hintCommand(args []string) {
+	flags := flag.NewFlagSet("hint", flag.ExitOnError)
+	moveTime := flags.Duration("time", hintTime, "time to search")
+	flags.Parse(args)
+
+	fen := startFEN
+	if flags.NArg() > 0 {
+		fen = strings.Join(flags.Args(), " ")
+	}
+	board, player, err := parseFEN(fen)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	h, ok := hint(board, player, engineOptions, *moveTime)
+	if !ok {
+		fmt.Println("No move to suggest")
+		return
+	}
+	fmt.Println(h.Move, h.summary())
+}
+
+// evalCommand shows the evaluation of a position term by term
+func evalCommand(args []string) {
+	fen := startFEN
+	if len(args) > 0 {
+		fen = strings.Join(args, " ")
+	}
+	board, _, err := parseFEN(fen)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	board.print()
+	board.trace(engineWeights()).print()
+	if network, ok := engineOptions.Evaluator.(*Network); ok {
+		fmt.Printf("Network: %+.2f for Self (black)\n", network.Evaluate(board))
+	}
+}
+
+// calibrateCommand estimates the ratings of the levels from games between them
+func calibrateCommand(args []string) {
+	flags := flag.NewFlagSet("calibrate", flag.ExitOnError)
+	games := flags.Int("games", 10, "number of games between each pair of levels")
+	flags.Parse(args)
+	calibrate(*games)
+}
+
+// tuneCommand tunes the weights of the engine on a data file and writes them to a file
+func tuneCommand(args []string) {
+	flags := flag.NewFlagSet("tune", flag.ExitOnError)
+	data := flags.String("data", "", "file of quiet positions, a FEN or EPD per line with the result of the game")
+	out := flags.String("out", "tuned.json", "file to write the tuned weights to")
+	k := flags.Float64("k", 0, "scaling of the evaluation into a winning probability, fitted to the data when 0")
+	iterations := flags.Int("iterations", 100, "passes over the weights at most")
+	flags.Parse(args)
+	tuned, err := tune(engineWeights(), *data, *k, *iterations)
+	if err == nil {
+		err = tuned.save(*out)
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// trainCommand trains a network on a data file or on self-play games and writes it to a file.
+// A network loaded with -network is trained further.
+func trainCommand(args []string) {
+	flags := flag.NewFlagSet("train", flag.ExitOnError)
+	data := flags.String("data", "", "file of positions like for tune, self-play games when not set")
+	games := flags.Int("games", 20, "number of self-play games")
+	levelName := flags.String("level", "medium", "level of the self-play games")
+	hidden := flags.Int("hidden", 32, "number of hidden neurons of a new network")
+	epochs := flags.Int("epochs", 20, "passes over the positions")
+	rate := flags.Float64("rate", 0.05, "learning rate")
+	lambda := flags.Float64("lambda", 0.5, "share of the game result in what the network learns, the rest from the handcrafted evaluation")
+	out := flags.String("out", "network.json", "file to write the network to")
+	flags.Parse(args)
+
+	var (
+		positions []tuningPosition
+		err       error
+	)
+	if *data != "" {
+		positions, err = loadTuningPositions(*data)
+	} else {
+		var level *Level
+		if level, err = getLevel(*levelName); err == nil {
+			positions = selfPlay(*games, level)
+		}
+	}
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	network, ok := engineOptions.Evaluator.(*Network)
+	if !ok {
+		network = newNetwork(*hidden, rand.New(rand.NewSource(1)))
+	}
+	train(network, positions, *epochs, *rate, *lambda)
+	if err := network.save(*out); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// weightsCommand writes the weights of the evaluation to a file, to start a new personality from
+func weightsCommand(args []string) {
+	flags := flag.NewFlagSet("weights", flag.ExitOnError)
+	out := flags.String("out", "weights.json", "file to write the weights to")
+	flags.Parse(args)
+	if err := engineWeights().save(*out); err != nil {
+		fmt.Println(err)
+	}
+}
+
 func getPositionFromInput(input string) Position {
 	return Position{7 - (int(input[1]) - 49), int(input[0]) - 97}
 }
@@ -110,12 +340,3 @@ func validateInput(input string) bool {
 	}
 	return true
 }
-
-func contains(positions []Position, loc Position) bool {
-	for _, v := range positions {
-		if v == loc {
-			return true
-		}
-	}
-	return false
-}
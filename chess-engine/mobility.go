@@ -0,0 +1,123 @@
+/*
+Contains the evaluation of the mobility and the activity of the pieces, from maps of the
+squares they attack rather than lists of moves.
+*/
+package main
+
+import (
+	"math/bits"
+)
+
+// getActivityScore scores the mobility and the activity of the pieces for Self, in pawns
+func (board Board) getActivityScore(weights *Weights, phase int) float64 {
+	selfMg, selfEg := board.getActivity(weights, Self)
+	userMg, userEg := board.getActivity(weights, User)
+	return taper(selfMg-userMg, selfEg-userEg, phase)
+}
+
+// getActivity scores the mobility and the activity of the pieces of player for the middlegame and the endgame
+func (board Board) getActivity(weights *Weights, player Color) (mg float64, eg float64) {
+	enemy := opponent(player)
+	forward := getForward(player)
+	// squares the pieces can go to without being taken by a pawn
+	area := ^(board.occupancy(player) | board.pawnAttacks(enemy))
+	bishops := 0
+
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			if board[i][j].getPlayer() != player {
+				continue
+			}
+			index := board[i][j].Index() % 6
+			term := weights.Mobility[index]
+			if term.Mg == 0 && term.Eg == 0 {
+				continue
+			}
+			mobility := bits.OnesCount64(board.attacks(Position{i, j}) & area)
+			mg += term.Mg * float64(mobility-weights.MobilityBase[index])
+			eg += term.Eg * float64(mobility-weights.MobilityBase[index])
+			rank := getRank(i, player)
+
+			switch board[i][j].(type) {
+			case *Rook:
+				if !board.hasPawnOnFile(j, player, 0, 7) {
+					if board.hasPawnOnFile(j, enemy, 0, 7) {
+						mg, eg = mg+weights.RookSemiOpen.Mg, eg+weights.RookSemiOpen.Eg
+					} else {
+						mg, eg = mg+weights.RookOpen.Mg, eg+weights.RookOpen.Eg
+					}
+				}
+				if rank == 6 && (board.hasPawnOnRow(i, enemy) || board.isKingOnRow(i+forward, enemy)) {
+					mg, eg = mg+weights.RookSeventh.Mg, eg+weights.RookSeventh.Eg
+				}
+				if rank == 0 && mobility <= 3 {
+					if king, err := board.findPiece(King{player}); err == nil && king.row == i &&
+						((king.col >= 5 && j > king.col) || (king.col <= 2 && j < king.col)) {
+						mg, eg = mg+weights.TrappedRook.Mg, eg+weights.TrappedRook.Eg
+					}
+				}
+			case *Bishop:
+				bishops++
+				if rank == 6 && (j == 0 || j == 7) && board.isPawn(i-forward, 1+(j/7)*5, enemy) {
+					mg, eg = mg+weights.TrappedBishop.Mg, eg+weights.TrappedBishop.Eg
+				}
+			case *Knight:
+				if rank >= 3 && rank <= 5 && (board.isPawn(i-forward, j-1, player) || board.isPawn(i-forward, j+1, player)) &&
+					!board.hasPawnOnFile(j-1, enemy, i+forward, i+7*forward) && !board.hasPawnOnFile(j+1, enemy, i+forward, i+7*forward) {
+					mg, eg = mg+weights.Outpost.Mg, eg+weights.Outpost.Eg
+				}
+			}
+		}
+	}
+	if bishops >= 2 {
+		mg, eg = mg+weights.BishopPair.Mg, eg+weights.BishopPair.Eg
+	}
+	return
+}
+
+// occupancy gives the squares of the pieces of player
+func (board Board) occupancy(player Color) (squares uint64) {
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			if board[i][j].getPlayer() == player {
+				squares |= 1 << (i*8 + j)
+			}
+		}
+	}
+	return
+}
+
+// pawnAttacks gives the squares attacked by the pawns of player
+func (board Board) pawnAttacks(player Color) (squares uint64) {
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			if board.isPawn(i, j, player) {
+				squares |= board.attacks(Position{i, j})
+			}
+		}
+	}
+	return
+}
+
+// hasPawnOnRow tells if player has a pawn on the row
+func (board Board) hasPawnOnRow(row int, player Color) bool {
+	for j := 0; j < 8; j++ {
+		if board.isPawn(row, j, player) {
+			return true
+		}
+	}
+	return false
+}
+
+// isKingOnRow tells if the king of player stands on the row, which may be off the board
+func (board Board) isKingOnRow(row int, player Color) bool {
+	if row < 0 || row > 7 {
+		return false
+	}
+	for j := 0; j < 8; j++ {
+		if _, ok := board[row][j].(*King); ok && board[row][j].getPlayer() == player {
+			return true
+		}
+	}
+	return false
+}
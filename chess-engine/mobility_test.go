@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestActivity(t *testing.T) {
+	activity := func(fen string) float64 {
+		board, _, err := parseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mg, _ := board.getActivity(defaultWeights, User)
+		return mg
+	}
+	for _, test := range []struct {
+		name          string
+		better, worse string
+	}{
+		{"knight in the center", "k7/8/8/8/3N4/8/8/7K w - - 0 1", "k7/8/8/8/8/8/8/N6K w - - 0 1"},
+		{"rook on an open file", "k7/8/8/8/8/8/8/3R3K w - - 0 1", "k7/8/8/8/8/8/3P4/3R3K w - - 0 1"},
+		{"rook on the seventh rank", "k7/pR6/8/8/8/8/8/7K w - - 0 1", "k7/p7/1R6/8/8/8/8/7K w - - 0 1"},
+		{"bishop pair", "k7/8/8/8/8/8/8/2B2B1K w - - 0 1", "k7/8/8/8/8/8/8/2B2N1K w - - 0 1"},
+		{"knight outpost", "k7/8/8/3N4/4P3/8/8/7K w - - 0 1", "k7/8/8/3N4/8/4P3/8/7K w - - 0 1"},
+	} {
+		if better, worse := activity(test.better), activity(test.worse); better <= worse {
+			t.Errorf("%s: got %v for %s, not more than %v for %s", test.name, better, test.better, worse, test.worse)
+		}
+	}
+}
+
+func TestActivityOfStart(t *testing.T) {
+	board, _, _ := parseFEN(startFEN)
+	if score := board.getActivityScore(defaultWeights, 0); score != 0 {
+		t.Errorf("got %v for the start position, want 0", score)
+	}
+}
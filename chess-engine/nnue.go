@@ -0,0 +1,162 @@
+/*
+Contains a small neural network evaluating boards, updated move by move during the search.
+*/
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"os"
+)
+
+// networkInputs are the features of a board: one for each piece on each square
+const networkInputs = 12 * 64
+
+// Network scores boards for Self in pawns with one hidden layer of clipped ReLU neurons.
+// The hidden layer before activation, the accumulator, is the sum of the input weights of
+// the pieces on the board, so a move only subtracts and adds the weights of the pieces it moves.
+type Network struct {
+	Hidden int `json:"Hidden"`
+	// InputWeights of each hidden neuron by feature, feature*Hidden + neuron
+	InputWeights  []float32 `json:"InputWeights"`
+	HiddenBias    []float32 `json:"HiddenBias"`
+	OutputWeights []float32 `json:"OutputWeights"`
+	OutputBias    float32   `json:"OutputBias"`
+
+	// salt keeps the scores of the network apart from the others in the caches
+	salt int
+}
+
+// newNetwork makes a network with random weights to train
+func newNetwork(hidden int, r *rand.Rand) *Network {
+	network := &Network{
+		Hidden:        hidden,
+		InputWeights:  make([]float32, networkInputs*hidden),
+		HiddenBias:    make([]float32, hidden),
+		OutputWeights: make([]float32, hidden),
+		salt:          rand.Int(),
+	}
+	for i := range network.InputWeights {
+		network.InputWeights[i] = float32(r.NormFloat64() * 0.05)
+	}
+	for i := range network.OutputWeights {
+		network.OutputWeights[i] = float32(r.NormFloat64() / math.Sqrt(float64(hidden)))
+	}
+	return network
+}
+
+// loadNetwork reads a network from a JSON file written by the train command
+func loadNetwork(path string) (*Network, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	network := &Network{}
+	if err := json.NewDecoder(file).Decode(network); err != nil {
+		return nil, err
+	}
+	if network.Hidden <= 0 || len(network.InputWeights) != networkInputs*network.Hidden ||
+		len(network.HiddenBias) != network.Hidden || len(network.OutputWeights) != network.Hidden {
+		return nil, errors.New("Invalid network in " + path)
+	}
+	network.salt = rand.Int()
+	return network, nil
+}
+
+// save writes the network to a JSON file
+func (network *Network) save(path string) error {
+	data, err := json.Marshal(network)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Evaluate scores the board from scratch
+func (network *Network) Evaluate(board Board) float64 {
+	accumulator := make([]float32, network.Hidden)
+	network.refresh(board, accumulator)
+	return network.output(accumulator)
+}
+
+func (network *Network) key() int {
+	return network.salt
+}
+
+// getFeature gives the input of the piece at position
+func getFeature(piece Piece, position Position) int {
+	return piece.Index()*64 + position.row*8 + position.col
+}
+
+// refresh fills the accumulator with the pieces of the board
+func (network *Network) refresh(board Board, accumulator []float32) {
+	copy(accumulator, network.HiddenBias)
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			if board[i][j].Index() != -1 {
+				network.add(accumulator, getFeature(board[i][j], Position{i, j}), 1)
+			}
+		}
+	}
+}
+
+// update sets the accumulator of the child from the one of the board before the move is made on it
+func (network *Network) update(parent []float32, board Board, move Move, child []float32) {
+	copy(child, parent)
+	piece := board[move.From.row][move.From.col]
+	network.add(child, getFeature(piece, move.From), -1)
+	network.add(child, getFeature(piece, move.To), 1)
+	if captured := board[move.To.row][move.To.col]; captured.Index() != -1 {
+		network.add(child, getFeature(captured, move.To), -1)
+	}
+}
+
+// add adds the input weights of the feature to the accumulator, sign times
+func (network *Network) add(accumulator []float32, feature int, sign float32) {
+	weights := network.InputWeights[feature*network.Hidden : (feature+1)*network.Hidden]
+	for i, weight := range weights {
+		accumulator[i] += sign * weight
+	}
+}
+
+// output scores the board of the accumulator
+func (network *Network) output(accumulator []float32) float64 {
+	score := network.OutputBias
+	for i, value := range accumulator {
+		score += network.OutputWeights[i] * clippedReLU(value)
+	}
+	return float64(score)
+}
+
+func clippedReLU(value float32) float32 {
+	if value < 0 {
+		return 0
+	}
+	if value > 1 {
+		return 1
+	}
+	return value
+}
+
+// evaluate scores the leaf at depth, from the accumulator kept along the moves searched with a network
+func (s *searcher) evaluate(depth int, board Board) float64 {
+	if s.network != nil {
+		return s.network.output(s.accumulators[depth])
+	}
+	return s.evaluator.Evaluate(board)
+}
+
+// makeMove updates the accumulator of the child of the node at depth, with a network
+func (s *searcher) makeMove(depth int, board Board, child Tree) {
+	if s.network == nil {
+		return
+	}
+	if depth == 0 {
+		s.network.refresh(board, s.accumulators[0])
+	}
+	s.network.update(s.accumulators[depth], board, Move{child.oldPos, child.newPos}, s.accumulators[depth+1])
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// sameAccumulators tells if the accumulators match, up to the rounding errors of float32 sums
+func sameAccumulators(a []float32, b []float32) bool {
+	for i := range a {
+		if math.Abs(float64(a[i]-b[i])) > 1e-4 {
+			return false
+		}
+	}
+	return true
+}
+
+// checkUpdate tells if updating the accumulator of the board with the move of node gives the one of a refresh
+func checkUpdate(t *testing.T, network *Network, parent []float32, board Board, node Tree) []float32 {
+	t.Helper()
+	move := Move{node.oldPos, node.newPos}
+	child := make([]float32, network.Hidden)
+	network.update(parent, board, move, child)
+	full := make([]float32, network.Hidden)
+	network.refresh(node.board, full)
+	if !sameAccumulators(child, full) {
+		t.Fatalf("the accumulator after %v is %v, want %v", move, child, full)
+	}
+	if math.Abs(network.output(child)-network.Evaluate(node.board)) > 1e-3 {
+		t.Fatalf("the output after %v differs from a full evaluation", move)
+	}
+	return child
+}
+
+func TestNetworkUpdateMatchesRefresh(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	network := newNetwork(16, r)
+	board, player, _ := parseFEN(startFEN)
+	parent := make([]float32, network.Hidden)
+	network.refresh(board, parent)
+	for ply := 0; ply < 30; ply++ {
+		nodes := board.generateNodes(player)
+		if len(nodes) == 0 {
+			break
+		}
+		node := nodes[r.Intn(len(nodes))]
+		parent = checkUpdate(t, network, parent, board, node)
+		board, player = node.board, opponent(player)
+	}
+
+	// a capture takes the captured piece off the accumulator
+	board, player, _ = parseFEN("k7/8/8/3p4/4P3/8/8/7K w - - 0 1")
+	network.refresh(board, parent)
+	for _, node := range board.generateNodes(player) {
+		if node.board[3][3].Index() == (Pawn{User}).Index() {
+			checkUpdate(t, network, parent, board, node)
+			return
+		}
+	}
+	t.Fatal("e4d5 was not generated")
+}
+
+func TestSaveNetwork(t *testing.T) {
+	dir := t.TempDir()
+	network := newNetwork(8, rand.New(rand.NewSource(1)))
+	path := filepath.Join(dir, "net.json")
+	if err := network.save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadNetwork(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	board, _, _ := parseFEN(startFEN)
+	if loaded.Evaluate(board) != network.Evaluate(board) {
+		t.Errorf("the loaded network scores %v, want %v", loaded.Evaluate(board), network.Evaluate(board))
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte(`{"Hidden": 8, "InputWeights": [1, 2]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadNetwork(invalid); err == nil {
+		t.Error("a network with missing weights was loaded")
+	}
+}
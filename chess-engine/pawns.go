@@ -0,0 +1,172 @@
+/*
+Contains the evaluation of the pawn structure, cached by a hash of the pawns only.
+*/
+package main
+
+import (
+	"sync"
+)
+
+// pawnCacheSize is the number of pawn structures kept in the cache
+const pawnCacheSize = 1 << 14
+
+// pawnEntry holds the score of a pawn structure
+type pawnEntry struct {
+	key    int
+	filled bool
+	// scores of the pawns of each side by Color
+	scores [2]Term
+	// passed pawns, with bit row*8+col set
+	passed uint64
+}
+
+var (
+	pawnCache      [pawnCacheSize]pawnEntry
+	pawnCacheMutex = &sync.Mutex{}
+)
+
+// pawnHash hashes the pawns of the board, leaving out the other pieces
+func (board Board) pawnHash() int {
+	hash := 0
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			if index := board[i][j].Index(); index == (Pawn{Self}).Index() || index == (Pawn{User}).Index() {
+				hash ^= zorbistTable[i][j][index]
+			}
+		}
+	}
+	return hash
+}
+
+// getPawnScore scores the pawn structure for Self, in pawns
+func (board Board) getPawnScore(weights *Weights, phase int) float64 {
+	scores := board.getPawns(weights)
+	return taper(scores[Self].Mg-scores[User].Mg, scores[Self].Eg-scores[User].Eg, phase)
+}
+
+// getPawns scores the pawn structure of each side by Color, with a bonus for
+// passed pawns whose path to the last rank is free
+func (board Board) getPawns(weights *Weights) [2]Term {
+	key := board.pawnHash() ^ weights.salt
+	slot := &pawnCache[key&(pawnCacheSize-1)]
+	pawnCacheMutex.Lock()
+	entry := *slot
+	pawnCacheMutex.Unlock()
+	if !entry.filled || entry.key != key {
+		entry = board.evaluatePawns(weights)
+		entry.key = key
+		pawnCacheMutex.Lock()
+		*slot = entry
+		pawnCacheMutex.Unlock()
+	}
+
+	scores := entry.scores
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			if entry.passed&(1<<(i*8+j)) == 0 || !board.isPathFree(Position{i, j}) {
+				continue
+			}
+			player := board[i][j].getPlayer()
+			rank := getRank(i, player)
+			scores[player].Mg += weights.FreePassed * weights.Passed[rank].Mg
+			scores[player].Eg += weights.FreePassed * weights.Passed[rank].Eg
+		}
+	}
+	return scores
+}
+
+// evaluatePawns scores doubled, isolated, backward, blocked, connected and passed pawns
+func (board Board) evaluatePawns(weights *Weights) (entry pawnEntry) {
+	entry.filled = true
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			player := board[i][j].getPlayer()
+			if !board.isPawn(i, j, player) {
+				continue
+			}
+			mg, eg := 0.0, 0.0
+			enemy := opponent(player)
+			forward := getForward(player)
+			isolated := !board.hasPawnOnFile(j-1, player, 0, 7) && !board.hasPawnOnFile(j+1, player, 0, 7)
+
+			doubled := board.hasPawnOnFile(j, player, i+forward, i+7*forward)
+			if doubled {
+				mg, eg = mg+weights.Doubled.Mg, eg+weights.Doubled.Eg
+			}
+			if isolated {
+				mg, eg = mg+weights.Isolated.Mg, eg+weights.Isolated.Eg
+			}
+			if board.isPawn(i+forward, j, enemy) {
+				mg, eg = mg+weights.Blocked.Mg, eg+weights.Blocked.Eg
+			}
+			if board.isPawn(i-forward, j-1, player) || board.isPawn(i-forward, j+1, player) ||
+				board.isPawn(i, j-1, player) || board.isPawn(i, j+1, player) {
+				mg, eg = mg+weights.Connected.Mg, eg+weights.Connected.Eg
+			}
+			// backward pawns can't be defended by pawns and can't advance safely
+			if !isolated && !board.hasPawnOnFile(j-1, player, i, i-7*forward) && !board.hasPawnOnFile(j+1, player, i, i-7*forward) &&
+				(board.isPawn(i+2*forward, j-1, enemy) || board.isPawn(i+2*forward, j+1, enemy)) {
+				mg, eg = mg+weights.Backward.Mg, eg+weights.Backward.Eg
+			}
+			if !doubled && !board.hasPawnOnFile(j-1, enemy, i+forward, i+7*forward) && !board.hasPawnOnFile(j, enemy, i+forward, i+7*forward) &&
+				!board.hasPawnOnFile(j+1, enemy, i+forward, i+7*forward) {
+				entry.passed |= 1 << (i*8 + j)
+				rank := getRank(i, player)
+				mg, eg = mg+weights.Passed[rank].Mg, eg+weights.Passed[rank].Eg
+			}
+
+			entry.scores[player].Mg += mg
+			entry.scores[player].Eg += eg
+		}
+	}
+	return
+}
+
+// isPawn tells if there is a pawn of player at row, col, which may be off the board
+func (board Board) isPawn(row int, col int, player Color) bool {
+	if row < 0 || row > 7 || col < 0 || col > 7 {
+		return false
+	}
+	_, ok := board[row][col].(*Pawn)
+	return ok && board[row][col].getPlayer() == player
+}
+
+// hasPawnOnFile tells if player has a pawn on the column between two rows, in either order
+func (board Board) hasPawnOnFile(col int, player Color, from int, to int) bool {
+	if from > to {
+		from, to = to, from
+	}
+	for i := from; i <= to; i++ {
+		if board.isPawn(i, col, player) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPathFree tells if nothing stands in front of the pawn up to the last rank
+func (board Board) isPathFree(position Position) bool {
+	forward := getForward(board[position.row][position.col].getPlayer())
+	for i := position.row + forward; i >= 0 && i < 8; i += forward {
+		if board[i][position.col].getPlayer() != Undefined {
+			return false
+		}
+	}
+	return true
+}
+
+// getForward gives the direction the pawns of player move in, along the rows
+func getForward(player Color) int {
+	if player == Self {
+		return 1
+	}
+	return -1
+}
+
+// getRank gives the rank of a row counted from the side of player, 0 for its first rank
+func getRank(row int, player Color) int {
+	if player == Self {
+		return row
+	}
+	return 7 - row
+}
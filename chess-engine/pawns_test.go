@@ -0,0 +1,52 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEvaluatePawns(t *testing.T) {
+	w := defaultWeights
+	for _, test := range []struct {
+		name, fen string
+		mg, eg    float64
+		passed    []Position
+	}{
+		{"isolated passed pawn", "k7/8/8/4p3/8/8/8/7K w - - 0 1",
+			w.Isolated.Mg + w.Passed[3].Mg, w.Isolated.Eg + w.Passed[3].Eg, []Position{{3, 4}}},
+		{"doubled pawns", "k7/8/4p3/4p3/8/8/8/7K w - - 0 1",
+			w.Doubled.Mg + 2*w.Isolated.Mg + w.Passed[3].Mg, w.Doubled.Eg + 2*w.Isolated.Eg + w.Passed[3].Eg, []Position{{3, 4}}},
+		{"connected passed pawns", "k7/8/8/3pp3/8/8/8/7K w - - 0 1",
+			2 * (w.Connected.Mg + w.Passed[3].Mg), 2 * (w.Connected.Eg + w.Passed[3].Eg), []Position{{3, 3}, {3, 4}}},
+		{"blocked pawns", "k7/8/8/4p3/4P3/8/8/7K w - - 0 1", 0, 0, nil},
+		{"passed pawn of the user", "k7/8/8/8/8/8/4P3/7K w - - 0 1",
+			-(w.Isolated.Mg + w.Passed[1].Mg), -(w.Isolated.Eg + w.Passed[1].Eg), []Position{{6, 4}}},
+	} {
+		board, _, err := parseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry := board.evaluatePawns(w)
+		mg := entry.scores[Self].Mg - entry.scores[User].Mg
+		eg := entry.scores[Self].Eg - entry.scores[User].Eg
+		if math.Abs(mg-test.mg) > 1e-9 || math.Abs(eg-test.eg) > 1e-9 {
+			t.Errorf("%s: got %v/%v, want %v/%v", test.name, mg, eg, test.mg, test.eg)
+		}
+		passed := uint64(0)
+		for _, position := range test.passed {
+			passed |= 1 << (position.row*8 + position.col)
+		}
+		if entry.passed != passed {
+			t.Errorf("%s: got passed pawns %b, want %b", test.name, entry.passed, passed)
+		}
+	}
+}
+
+func TestFreePassedPawn(t *testing.T) {
+	free, _, _ := parseFEN("k7/8/8/4p3/8/8/8/7K w - - 0 1")
+	stopped, _, _ := parseFEN("k7/8/8/4p3/8/8/8/4K3 w - - 0 1")
+	if free.getPawnScore(defaultWeights, 0) <= stopped.getPawnScore(defaultWeights, 0) {
+		t.Errorf("a free passed pawn scored %v, not more than a stopped one at %v",
+			free.getPawnScore(defaultWeights, 0), stopped.getPawnScore(defaultWeights, 0))
+	}
+}
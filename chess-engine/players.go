@@ -0,0 +1,130 @@
+/*
+Contains games between two players through the server, each taking a seat with its token.
+*/
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Seats of a game between two players by Color: white plays the pieces of User and black those of Self
+type Seats struct {
+	// Tokens of the players, given once to the creator of the game to share
+	Tokens [2]string `json:"Tokens"`
+	// Joined tells which seats were taken, moves are played once both are
+	Joined [2]bool `json:"Joined"`
+	// Assistant lets the engine analyse the game once it is over
+	Assistant bool `json:"Assistant,omitempty"`
+}
+
+// TokensBody has the tokens of the players of a game, to join it and to play its moves
+type TokensBody struct {
+	White string `json:"White"`
+	Black string `json:"Black"`
+}
+
+// SeatsBody tells which seats of a game were taken
+type SeatsBody struct {
+	White bool `json:"White"`
+	Black bool `json:"Black"`
+}
+
+// JoinResponseBody is the game joined with the color of the player
+type JoinResponseBody struct {
+	Color string   `json:"Color"`
+	Game  GameBody `json:"Game"`
+}
+
+// JoinEventBody tells the clients of a game that a player took a seat
+type JoinEventBody struct {
+	Color string `json:"Color"`
+}
+
+// joinEvent when a player takes a seat, with a JoinEventBody
+const joinEvent = "join"
+
+// newSeats makes the seats of a game, with new tokens
+func newSeats(assistant bool) *Seats {
+	return &Seats{Tokens: [2]string{newGameID(), newGameID()}, Assistant: assistant}
+}
+
+// full tells if both players took their seat
+func (seats *Seats) full() bool {
+	return seats.Joined[White] && seats.Joined[Black]
+}
+
+// player finds the color of the token of the request, sent as Authorization: Bearer <token>
+func (seats *Seats) player(r *http.Request) (Color, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if ok && token != "" {
+		for _, color := range []Color{White, Black} {
+			if seats.Tokens[color] == token {
+				return color, nil
+			}
+		}
+	}
+	return Undefined, &gameError{status: http.StatusForbidden, code: codeForbidden, message: "The token of a seat of the game is needed"}
+}
+
+// turn checks that the request carries the token of the player to move, once both seats are taken
+func (seats *Seats) turn(r *http.Request, toMove Color) error {
+	color, err := seats.player(r)
+	if err != nil {
+		return err
+	}
+	if !seats.full() {
+		return &gameError{status: http.StatusConflict, code: codeWaiting, message: "Waiting for the opponent to join"}
+	}
+	if color != toMove {
+		return &gameError{status: http.StatusConflict, code: codeNotYourTurn, message: "It is the turn of " + colorName(toMove)}
+	}
+	return nil
+}
+
+// colorName gives white or black
+func colorName(color Color) string {
+	if color == White {
+		return "white"
+	}
+	return "black"
+}
+
+// joinGame takes the seat of the token of the request. Clocks start once both seats are taken.
+func joinGame(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+	w.Header().Set("Content-Type", "application/json")
+	id := r.PathValue("id")
+	game, ok := store.Get(id)
+	if !ok {
+		writeError(w, errGameNotFound)
+		return
+	}
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	if game.Seats == nil {
+		writeError(w, &gameError{status: http.StatusConflict, code: codeBadRequest, message: "The game is played against the engine"})
+		return
+	}
+	color, err := game.Seats.player(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if !game.Seats.Joined[color] {
+		game.Seats.Joined[color] = true
+		if game.Seats.full() {
+			game.turnStart = time.Now()
+		}
+		game.events.publish(joinEvent, JoinEventBody{colorName(color)})
+		if err := store.Put(id, game); err != nil {
+			log.Println("Could not save game", id, err)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(JoinResponseBody{Color: colorName(color), Game: game.body(id)})
+}
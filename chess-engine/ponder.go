@@ -0,0 +1,70 @@
+/*
+Contains pondering, searching on the opponent's time.
+*/
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ponder is a search of the reply to the move expected from the opponent,
+// run while the opponent thinks. Whatever it finds is kept in the cache.
+type ponder struct {
+	// move expected from the opponent
+	move  Move
+	stop  atomic.Bool
+	done  chan struct{}
+	lines []Line
+	// time of a timed game, held until the opponent plays the expected move
+	time *timeManager
+	// limit stops the search once the opponent would have run out of time
+	limit *time.Timer
+}
+
+// startPonder searches for the reply of player after the opponent plays the expected move on board,
+// for the limit at most when it is not 0, the time left to the opponent in timed games
+func startPonder(board Board, player Color, move Move, options SearchOptions, limit time.Duration) *ponder {
+	p := &ponder{move: move, done: make(chan struct{}), time: options.Time}
+	if p.time != nil {
+		p.time.ponder()
+	}
+	if limit > 0 {
+		p.limit = time.AfterFunc(limit, func() { p.stop.Store(true) })
+	}
+	options.Stop = &p.stop
+	go func() {
+		defer close(p.done)
+		p.lines = analyse(First(board.movePiece(move.From, move.To)), player, options)
+	}()
+	return p
+}
+
+// expectedMove is the reply of the opponent in the best line, the move to ponder on
+func expectedMove(lines []Line) (Move, bool) {
+	if len(lines) == 0 || len(lines[0].Moves) < 2 {
+		return Move{}, false
+	}
+	return lines[0].Moves[1], true
+}
+
+// hit waits for the search to end when the opponent played the expected move, and gives its lines
+func (p *ponder) hit() []Line {
+	if p.limit != nil {
+		p.limit.Stop()
+	}
+	if p.time != nil {
+		p.time.ponderHit()
+	}
+	<-p.done
+	return p.lines
+}
+
+// miss stops the search when the opponent played another move
+func (p *ponder) miss() {
+	if p.limit != nil {
+		p.limit.Stop()
+	}
+	p.stop.Store(true)
+	<-p.done
+}
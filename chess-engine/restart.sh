@@ -1,5 +1,5 @@
 pm2 delete engine
 rm -rf engine
-go build engine.go board.go pieces.go server.go
-pm2 start engine
+go build engine.go board.go fen.go san.go hint.go pieces.go eval.go pawns.go king.go attacks.go mobility.go weights.go nnue.go trace.go see.go search.go timeman.go ponder.go levels.go store.go game.go events.go errors.go api.go players.go handlers.go server.go
+pm2 start engine -- -store games.json
 
@@ -0,0 +1,59 @@
+/*
+Contains standard algebraic notation (SAN) of moves, like Nf3, exd5 or Qh5+.
+*/
+package main
+
+import (
+	"strings"
+)
+
+// san gives the move of player on board in standard algebraic notation. The piece letter is
+// followed by the file, the rank or both of the square it leaves when another piece of the same
+// kind can go to the same square.
+func (board Board) san(player Color, move Move) string {
+	var sb strings.Builder
+	piece := board[move.From.row][move.From.col]
+	capture := board[move.To.row][move.To.col].getPlayer() == opponent(player)
+	from := move.From.String()
+
+	if _, ok := piece.(*Pawn); ok {
+		if capture {
+			sb.WriteByte(from[0])
+		}
+	} else {
+		sb.WriteString(strings.TrimSuffix(piece.String(), "'"))
+		ambiguous, sameFile, sameRank := false, false, false
+		for _, node := range board.generateNodes(player) {
+			other := node.oldPos
+			if node.newPos == move.To && other != move.From && board[other.row][other.col].String() == piece.String() {
+				ambiguous = true
+				sameFile = sameFile || other.col == move.From.col
+				sameRank = sameRank || other.row == move.From.row
+			}
+		}
+		if ambiguous {
+			switch {
+			case !sameFile:
+				sb.WriteByte(from[0])
+			case !sameRank:
+				sb.WriteByte(from[1])
+			default:
+				sb.WriteString(from)
+			}
+		}
+	}
+	if capture {
+		sb.WriteByte('x')
+	}
+	sb.WriteString(move.To.String())
+
+	after := First(board.movePiece(move.From, move.To))
+	if after.check(opponent(player)) == 1 {
+		if len(after.generateNodes(opponent(player))) == 0 {
+			sb.WriteByte('#')
+		} else {
+			sb.WriteByte('+')
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestSAN(t *testing.T) {
+	for _, test := range []struct{ fen, move, san string }{
+		{startFEN, "g1f3", "Nf3"},
+		{startFEN, "e2e3", "e3"},
+		{startFEN, "b8c6", "Nc6"},
+		{"7k/6pp/8/8/8/8/6PP/R6K w - - 0 1", "a1a8", "Ra8#"},
+		{"7k/6pp/8/8/8/8/6PP/R4R1K w - - 0 1", "a1b1", "Rab1"},
+		{"7k/8/8/8/8/8/R7/R6K w - - 0 1", "a1a8", "R1a8+"},
+		{"7k/8/3p4/4n3/3P4/8/8/7K w - - 0 1", "d4e5", "dxe5"},
+		{"7k/8/8/4n3/8/8/8/4Q2K w - - 0 1", "e1e5", "Qxe5+"},
+	} {
+		board, player, err := parseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		move, err := parseMove(test.move)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := board.san(player, move); got != test.san {
+			t.Errorf("%s in %s: got %s, want %s", test.move, test.fen, got, test.san)
+		}
+	}
+}
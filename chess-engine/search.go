@@ -0,0 +1,305 @@
+/*
+Contains the search driver that runs miniMax on one or more threads.
+*/
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SearchOptions to configure a search of the MiniMax tree
+type SearchOptions struct {
+	// Depth of the MiniMax tree, MaxDepth when not set
+	Depth int
+	// Threads searching the tree together, 1 when not set
+	Threads int
+	// MultiPV is the number of best lines to find, 1 when not set
+	MultiPV int
+	// Deterministic searches on a single thread with a fixed move order and
+	// a cache of its own, so the same position always gets the same move
+	Deterministic bool
+	// Info is called with every line found, ranked from 1, after each depth is searched
+	Info func(rank int, line Line)
+	// Stop ends the search when set, with the lines of the last depth searched in full
+	Stop *atomic.Bool
+	// Time limits the search in timed games, searching up to Depth
+	Time *timeManager
+	// Level weakens the engine, full strength when not set
+	Level *Level
+	// Evaluator of the positions, defaultWeights when not set
+	Evaluator Evaluator
+}
+
+// Line of play found by the search, starting with the move to play
+type Line struct {
+	Moves []Move
+	// Score of the line for Self
+	Score float64
+	// Depth the line was searched to
+	Depth int
+}
+
+// engineOptions used for the engine's moves, set from the command line
+var engineOptions = SearchOptions{Depth: MaxDepth, Threads: 1}
+
+// searcher is a single thread of a search
+type searcher struct {
+	// depth of the tree searched in the current iteration
+	depth int
+	// exclude moves at the root, already found in better lines
+	exclude []Move
+	rand    *rand.Rand
+	stop    *atomic.Bool
+	// abort set by the caller of the search
+	abort *atomic.Bool
+	time  *timeManager
+	// nodes searched so far, up to maxNodes when set
+	nodes    int
+	maxNodes int
+	// limited once the time or nodes are used up
+	limited bool
+	// noise added to the evaluation of positions
+	noise float64
+	// salt keeps the scores of noisy searches and other evaluators apart from the others in the cache
+	salt int
+	// cache of a deterministic search, the shared cache when nil
+	cache     []cacheEntry
+	evaluator Evaluator
+	// network is the evaluator when it is a Network, with its accumulator at each depth of the tree
+	network      *Network
+	accumulators [][]float32
+}
+
+// search finds the move of player to play
+func search(board Board, player Color, options SearchOptions) (oldPos Position, newPos Position, score float64) {
+	return bestMove(analyse(board, player, options), player, options.Level, pickRand(options))
+}
+
+// bestMove gives the first move of the line to play with its score,
+// the best line unless the level picks a weaker one
+func bestMove(lines []Line, player Color, level *Level, r *rand.Rand) (oldPos Position, newPos Position, score float64) {
+	line := lines[0]
+	if level != nil {
+		line = level.pick(lines, player, r)
+	}
+	if len(line.Moves) == 0 {
+		return Position{}, Position{}, line.Score
+	}
+	return line.Moves[0].From, line.Moves[0].To, line.Score
+}
+
+// analyse finds the best lines of player, best first, deepening the tree one ply at a time.
+// Without any move to play, a single line with no moves is returned.
+//
+// Extra threads use Lazy SMP: each helper searches the same tree with its own
+// move order, every other one a ply ahead of the main thread, and they only
+// share their results through the cache. The lines of the main thread are returned.
+func analyse(board Board, player Color, options SearchOptions) (lines []Line) {
+	depth, threads, multiPV := options.Depth, options.Threads, options.MultiPV
+	if depth <= 0 {
+		depth = MaxDepth
+	}
+	if threads <= 0 {
+		threads = 1
+	}
+	if multiPV <= 0 {
+		multiPV = 1
+	}
+	var (
+		maxNodes, salt int
+		noise          float64
+	)
+	if level := options.Level; level != nil {
+		if level.Depth < depth {
+			depth = level.Depth
+		}
+		if level.Skill < MaxSkill && multiPV < skillLines {
+			multiPV = skillLines
+		}
+		maxNodes, noise = level.Nodes, level.Noise
+		if noise > 0 {
+			salt = rand.Int()
+		}
+	}
+	evaluator := options.Evaluator
+	if evaluator == nil {
+		evaluator = defaultWeights
+	}
+	salt ^= evaluator.key()
+	seed := time.Now().UnixNano()
+	if options.Deterministic {
+		threads, seed = 1, 1
+	}
+
+	var (
+		stop atomic.Bool
+		wg   sync.WaitGroup
+	)
+	for i := 1; i < threads; i++ {
+		helper := &searcher{rand: rand.New(rand.NewSource(seed + int64(i))), stop: &stop, abort: options.Stop, noise: noise, salt: salt}
+		helper.useEvaluator(evaluator, depth)
+		wg.Add(1)
+		go func(first int) {
+			defer wg.Done()
+			for d := first; d <= depth && !helper.stopped(); d++ {
+				helper.depth = d
+				helper.miniMax(0, Tree{board: board}, player, MIN, MAX)
+			}
+		}(1 + i%2)
+	}
+
+	primary := &searcher{rand: rand.New(rand.NewSource(seed)), stop: &stop, abort: options.Stop, time: options.Time,
+		maxNodes: maxNodes, noise: noise, salt: salt}
+	primary.useEvaluator(evaluator, depth)
+	if options.Deterministic {
+		primary.cache = initCache(deterministicCacheSize)
+	}
+	// with a single move to play, there is no need to spend time on it
+	forced := options.Time != nil && len(board.generateNodes(player)) == 1
+	for d := 1; d <= depth; d++ {
+		primary.depth = d
+		primary.exclude = nil
+		found := primary.lines(board, player, multiPV)
+		if primary.stopped() {
+			break
+		}
+		previous := Line{}
+		if len(lines) > 0 {
+			previous = lines[0]
+		}
+		lines = found
+		if options.Info != nil {
+			for i, line := range lines {
+				options.Info(i+1, line)
+			}
+		}
+		// nothing changes once a mate is found
+		if len(lines[0].Moves) == 0 || math.Abs(lines[0].Score) == MAX {
+			break
+		}
+		if options.Time != nil && (forced || !options.Time.next(player, lines[0], previous)) {
+			break
+		}
+	}
+	stop.Store(true)
+	wg.Wait()
+	return
+}
+
+// useEvaluator evaluates the leaves of trees up to depth with evaluator
+func (s *searcher) useEvaluator(evaluator Evaluator, depth int) {
+	s.evaluator = evaluator
+	if network, ok := evaluator.(*Network); ok {
+		s.network = network
+		s.accumulators = make([][]float32, depth+1)
+		for i := range s.accumulators {
+			s.accumulators[i] = make([]float32, network.Hidden)
+		}
+	}
+}
+
+// stopped tells if the search must end. The first depth is always searched in full to have a move to play.
+func (s *searcher) stopped() bool {
+	return s.stop.Load() || (s.depth > 1 && (s.limited || (s.abort != nil && s.abort.Load())))
+}
+
+// lines searches the root once for every line, leaving out the first move of the lines found before
+func (s *searcher) lines(board Board, player Color, multiPV int) (lines []Line) {
+	for len(lines) < multiPV {
+		oldPos, newPos, score := s.miniMax(0, Tree{board: board}, player, MIN, MAX)
+		move := Move{oldPos, newPos}
+		if oldPos == newPos {
+			// no move beats being mated, any move left is played then
+			var ok bool
+			if move, ok = s.anyMove(board, player); !ok {
+				if len(lines) == 0 {
+					lines = append(lines, Line{Score: score, Depth: s.depth})
+				}
+				break
+			}
+		}
+		lines = append(lines, Line{
+			Moves: s.principalVariation(board, player, move),
+			Score: score,
+			Depth: s.depth,
+		})
+		s.exclude = append(s.exclude, move)
+	}
+	return
+}
+
+// anyMove gives a legal move of player at the root that is not excluded, false when there is none
+func (s *searcher) anyMove(board Board, player Color) (Move, bool) {
+	for _, node := range board.generateNodes(player) {
+		if move := (Move{node.oldPos, node.newPos}); !containsMove(s.exclude, move) {
+			return move, true
+		}
+	}
+	return Move{}, false
+}
+
+// probeCache finds the entry of the key in the cache of the search
+func (s *searcher) probeCache(key int) (cacheEntry, bool) {
+	if s.cache == nil {
+		return probeCache(key)
+	}
+	return lookUp(s.cache, key)
+}
+
+// storeCache keeps the entry in the cache of the search
+func (s *searcher) storeCache(key int, entry cacheEntry) {
+	if s.cache == nil {
+		storeCache(key, entry)
+		return
+	}
+	keep(s.cache, key, entry)
+}
+
+// principalVariation follows the best moves kept in the cache after the first move, up to the depth searched
+func (s *searcher) principalVariation(board Board, player Color, first Move) []Move {
+	moves := []Move{first}
+	board = First(board.movePiece(first.From, first.To))
+	player = opponent(player)
+	for len(moves) < s.depth {
+		entry, hit := s.probeCache(board.key(player) ^ s.salt)
+		move := Move{entry.oldPos, entry.newPos}
+		if !hit || !board.isLegal(player, move) {
+			break
+		}
+		moves = append(moves, move)
+		board = First(board.movePiece(move.From, move.To))
+		player = opponent(player)
+	}
+	return moves
+}
+
+// isLegal tells if player can make the move on board
+func (board Board) isLegal(player Color, move Move) bool {
+	for _, node := range board.generateNodes(player) {
+		if node.oldPos == move.From && node.newPos == move.To {
+			return true
+		}
+	}
+	return false
+}
+
+// scoreFor gives the score of the line from the point of view of player
+func (line Line) scoreFor(player Color) float64 {
+	if player == User && line.Score != 0 {
+		return -line.Score
+	}
+	return line.Score
+}
+
+func containsMove(moves []Move, move Move) bool {
+	for _, v := range moves {
+		if v == move {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// deterministicLines searches the position in FEN to the depth in the deterministic mode
+func deterministicLines(t *testing.T, fen string, depth int, multiPV int) []Line {
+	t.Helper()
+	board, player, err := parseFEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return analyse(board, player, SearchOptions{Depth: depth, MultiPV: multiPV, Deterministic: true})
+}
+
+func TestDeterministicSearchRepeats(t *testing.T) {
+	first := deterministicLines(t, startFEN, 3, 2)
+	// a parallel search fills the shared cache in between
+	board, player, _ := parseFEN(startFEN)
+	analyse(board, player, SearchOptions{Depth: 4, Threads: 2})
+	second := deterministicLines(t, startFEN, 3, 2)
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("lines changed from %v to %v", first, second)
+	}
+}
+
+func TestDeterministicSearchKeepsSharedCache(t *testing.T) {
+	clearCache()
+	deterministicLines(t, startFEN, 3, 1)
+	for _, entry := range cache {
+		if entry.filled {
+			t.Fatal("the deterministic search wrote to the shared cache")
+		}
+	}
+}
+
+func TestSearchFindsMate(t *testing.T) {
+	lines := deterministicLines(t, "7k/6pp/8/8/8/8/6PP/R6K w - - 0 1", 3, 1)
+	if got := lines[0].Moves[0].String(); got != "a1a8" {
+		t.Errorf("got %s, want a1a8", got)
+	}
+	if lines[0].scoreFor(User) != MAX {
+		t.Errorf("got score %v, want %v", lines[0].scoreFor(User), MAX)
+	}
+}
+
+func TestSearchMovesWhenMated(t *testing.T) {
+	// every move of black is mated, the search must still give one
+	lines := deterministicLines(t, "7k/R7/1R6/8/8/8/8/2K5 b - - 0 1", 3, 1)
+	if len(lines[0].Moves) == 0 || lines[0].Moves[0].String() != "h8g8" {
+		t.Errorf("got %v, want h8g8", lines[0].Moves)
+	}
+}
+
+func TestSearchWithoutMoves(t *testing.T) {
+	lines := deterministicLines(t, "k7/8/1Q6/8/8/8/8/2K5 b - - 0 1", 3, 1)
+	if len(lines) != 1 || len(lines[0].Moves) != 0 {
+		t.Errorf("got %v, want a single line without moves", lines)
+	}
+}
+
+func TestSearchMultiPV(t *testing.T) {
+	lines := deterministicLines(t, startFEN, 2, 3)
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3", len(lines))
+	}
+	board, player, _ := parseFEN(startFEN)
+	seen := map[Move]bool{}
+	for i, line := range lines {
+		if move := line.Moves[0]; seen[move] || !board.isLegal(player, move) {
+			t.Errorf("line %d starts with %v, repeated or illegal", i+1, move)
+		} else {
+			seen[move] = true
+		}
+		if i > 0 && line.scoreFor(player) > lines[i-1].scoreFor(player) {
+			t.Errorf("line %d scores better than line %d", i+1, i)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Contains the static exchange evaluation (SEE) of captures.
+*/
+package main
+
+// seeValues of the pieces in pawns by Piece.Index() % 6: knight, king, queen, rook, bishop, pawn.
+// The king is worth more than everything else together, so it never captures into a recapture.
+var seeValues = [6]float64{3, 100, 9, 5, 3, 1}
+
+// see gives the material won by the player making the move, in pawns, once the pieces of both sides
+// attacking the square it goes to have captured on it in turn, least valuable first. Either side stops
+// capturing when that loses material. Attackers behind a piece that captures join the exchange as the
+// piece leaves its square. A move that captures nothing scores what the opponent then wins taking it.
+func (board Board) see(move Move) float64 {
+	player := board[move.From.row][move.From.col].getPlayer()
+	gains := []float64{getSEEValue(board[move.To.row][move.To.col])}
+	board[move.To.row][move.To.col], board[move.From.row][move.From.col] = board[move.From.row][move.From.col], &Empty{}
+
+	for side := opponent(player); ; side = opponent(side) {
+		from, ok := board.leastValuableAttacker(move.To, side)
+		if !ok {
+			break
+		}
+		gains = append(gains, getSEEValue(board[move.To.row][move.To.col])-gains[len(gains)-1])
+		board[move.To.row][move.To.col], board[from.row][from.col] = board[from.row][from.col], &Empty{}
+	}
+
+	// going back through the exchange, each side takes or stops, whichever is better
+	for i := len(gains) - 1; i > 0; i-- {
+		if -gains[i] < gains[i-1] {
+			gains[i-1] = 0 - gains[i]
+		}
+	}
+	return gains[0]
+}
+
+// seeSquare gives the material player wins starting the exchange on the square with its least
+// valuable attacker, 0 when player has no piece attacking it
+func (board Board) seeSquare(square Position, player Color) float64 {
+	from, ok := board.leastValuableAttacker(square, player)
+	if !ok {
+		return 0
+	}
+	return board.see(Move{from, square})
+}
+
+// leastValuableAttacker finds the piece of player of the lowest value attacking the square
+func (board Board) leastValuableAttacker(square Position, player Color) (Position, bool) {
+	best, found := Position{}, false
+	for _, attacker := range getPositions(board.attackersTo(square, player)) {
+		if !found || getSEEValue(board[attacker.row][attacker.col]) < getSEEValue(board[best.row][best.col]) {
+			best, found = attacker, true
+		}
+	}
+	return best, found
+}
+
+// getSEEValue gives the value of the piece in the exchange, 0 for an empty square
+func getSEEValue(piece Piece) float64 {
+	if index := piece.Index(); index != -1 {
+		return seeValues[index%6]
+	}
+	return 0
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestSEE(t *testing.T) {
+	for _, test := range []struct {
+		fen, move string
+		gain      float64
+	}{
+		// a pawn takes a knight defended by a pawn
+		{"7k/8/3p4/4n3/3P4/8/8/7K w - - 0 1", "d4e5", 2},
+		// a queen takes a pawn defended by a pawn
+		{"7k/8/3p4/4p3/8/8/8/4Q2K w - - 0 1", "e1e5", -8},
+		// a rook takes an undefended knight
+		{"7k/8/8/4n3/8/8/8/4R2K w - - 0 1", "e1e5", 3},
+		// the rook behind the rook joins the exchange
+		{"7k/4r3/8/4p3/8/8/4R3/4R2K w - - 0 1", "e2e5", 1},
+		// the queen is lost for a pawn and a rook
+		{"7k/4r3/8/4p3/8/8/4Q3/4R2K w - - 0 1", "e2e5", -3},
+		// a move to a square the opponent takes on for free
+		{"7k/8/3p4/8/8/8/8/4N2K w - - 0 1", "e1f3", 0},
+		{"7k/8/3p4/8/8/3N4/8/7K w - - 0 1", "d3e5", -3},
+	} {
+		board, _, err := parseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		move := Move{getPositionFromInput(test.move[:2]), getPositionFromInput(test.move[2:])}
+		if got := board.see(move); got != test.gain {
+			t.Errorf("%s in %s: got %v, want %v", test.move, test.fen, got, test.gain)
+		}
+	}
+}
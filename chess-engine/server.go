@@ -5,14 +5,15 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"time"
 )
 
-var gameCache map[string]Board = make(map[string]Board)
-
 // MoveRequestBody received to move a piece
 type MoveRequestBody struct {
 	FromRow int `json:"FromRow"`
@@ -26,204 +27,169 @@ type MoveResponseBody struct {
 	Board [][]string `json:"Board"`
 	Check bool       `json:"Check"`
 	Mate  bool       `json:"Mate"`
+	// Status of the game after the move: playing, checkmate, stalemate or timeout
+	Status string      `json:"Status,omitempty"`
+	Clocks *ClocksBody `json:"Clocks,omitempty"`
 }
 
+// play is the endpoint of the first version of the API, creating the game with the id when it
+// does not exist, giving its board on GET and playing the move of the user on POST
 func play(w http.ResponseWriter, r *http.Request) {
 	enableCors(&w)
 	w.Header().Set("Content-Type", "application/json")
 	fmt.Println(r.URL.Path)
-	// if r.URL.Path != "/engine/api/" {
-	// 	http.Error(w, "404 not found.", http.StatusNotFound)
-	// 	return
-	// }
-	var (
-		oldPos, newPos, fromPos, toPos Position
-		score                          float64
-		allPos                         []Position
-	)
 	id := r.URL.Query().Get("id")
 	fmt.Println(id)
-	board, ok := gameCache[id]
+	game, ok := store.Get(id)
 	if !ok {
 		fmt.Println("Initialising a new game...")
-		board = Board{}
-		board.initialise()
-		gameCache[id] = board
+		options := GameOptions{Level: r.URL.Query().Get("level"), Personality: r.URL.Query().Get("personality")}
+		// timed games get the time and increment parameters in seconds
+		if seconds, err := strconv.Atoi(r.URL.Query().Get("time")); err == nil && seconds > 0 {
+			options.Time = seconds
+			options.Increment, _ = strconv.Atoi(r.URL.Query().Get("increment"))
+		}
+		var err error
+		if game, err = newGame(options); err != nil {
+			writeError(w, err)
+			return
+		}
+		if err := store.Put(id, game); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	game.mutex.Lock()
+	defer game.mutex.Unlock()
+	if game.Seats != nil {
+		writeError(w, &gameError{status: http.StatusConflict, code: codeBadRequest, message: "Use /v1 for games between two players"})
+		return
 	}
-	board.print()
+	game.Board.print()
 
 	switch r.Method {
 	case "GET":
-
-		// jData, err := json.Marshal(board.getAsSlice())
-		// if err != nil {
-		// 	fmt.Fprintf(w, "json.Marshal() err: %v", err)
-		// 	return
-		// }
 		var res MoveResponseBody
-		res.Board = board.getAsSlice()
+		res.Board = game.Board.getAsSlice()
 		res.Check = false
 		res.Mate = false
+		res.Clocks = game.clocks()
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(res)
 
 	case "POST":
 		var body MoveRequestBody
-		buf, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			fmt.Println("Could not decode request data", body)
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if err := decodeBody(r, &body, false); err != nil {
+			fmt.Println("Could not decode request data", err)
+			writeError(w, err)
 			return
 		}
-		json.Unmarshal(buf, &body)
 		fmt.Println(body)
 
-		fromPos, toPos = Position{body.FromRow, body.FromCol}, Position{body.ToRow, body.ToCol}
-
-		allPos, err = board[fromPos.row][fromPos.col].getAllMoves(board, fromPos)
-
-		if err != nil {
-			fmt.Println("No element found at ", fromPos)
-			http.Error(w, "No element found", http.StatusNonAuthoritativeInfo)
-			return
-		}
-
-		if !contains(allPos, toPos) {
-			fmt.Println("Not a valid move for element at position:", fromPos)
-			http.Error(w, "Not a valid move", http.StatusNotAcceptable)
+		if err := game.play(Move{Position{body.FromRow, body.FromCol}, Position{body.ToRow, body.ToCol}}); err != nil {
+			writeError(w, err)
 			return
 		}
-
-		if First(board.movePiece(fromPos, toPos)).check(User) == 1 {
-			fmt.Println("You cannot move here, your king will be in check position")
-			http.Error(w, "Cannot move here, King will be in CHECK state", http.StatusForbidden)
-			return
+		if err := store.Put(id, game); err != nil {
+			log.Println("Could not save game", id, err)
 		}
-		board.makeMove(fromPos, toPos)
-
-		//check for stalemate by generating all moves
-		fmt.Println("Hmm....nice move....you have forced me to hit my nerves...")
-		oldPos, newPos, score = miniMax(0, Tree{board: board}, Self, MIN, MAX)
-		fmt.Println(oldPos, newPos, score)
-
-		if score == MIN {
-			fmt.Println("Looks like no moves left for me !")
-			http.Error(w, "No moves left for me :( ", http.StatusNoContent)
-			return
-		}
-
-		board.makeMove(oldPos, newPos)
-		gameCache[id] = board
+		board := game.Board
 		board.print()
 		var res MoveResponseBody
 		res.Board = board.getAsSlice()
 		res.Check = false
 		res.Mate = false
+		res.Clocks = game.clocks()
+		res.Status = game.status()
 
 		if board.check(User) == 1 {
 			fmt.Println("CHECK !")
 			res.Check = true
 		}
 
-		if len(board.generateNodes(User)) == 0 {
+		if game.ToMove == User && len(board.generateNodes(User)) == 0 {
 			fmt.Println("OR Looks like CHECK AND MATE !")
 			res.Mate = true
 		}
-
-		// jData, err := json.Marshal(res)
-		// if err != nil {
-		// 	fmt.Fprintf(w, "json.Marshal() err: %v", err)
-		// 	return
-		// }
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(res)
 
 	default:
-		fmt.Fprintf(w, "Sorry, only GET and POST methods are supported.")
+		writeError(w, &gameError{status: http.StatusMethodNotAllowed, code: codeNotAllowed, message: "Only GET and POST methods are supported"})
 	}
 }
 
 func main() {
-	http.HandleFunc("/", play)
-
-	fmt.Printf("Starting Chess Server...\n")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
-		log.Fatal(err)
+	flag.IntVar(&engineOptions.Threads, "threads", 1, "number of threads searching for a move")
+	flag.BoolVar(&pondering, "ponder", true, "search for the engine's reply while the user thinks")
+	weightsPath := flag.String("weights", "", "JSON file with the weights of the evaluation")
+	personalitiesDir := flag.String("personalities", "", "directory of JSON weights files games can choose from by name")
+	storePath := flag.String("store", "", "JSON file keeping the games across restarts, games are kept in memory when not set")
+	networkPath := flag.String("network", "", "JSON file of a network evaluating positions instead of the weights")
+	ttl := flag.Duration("ttl", 24*time.Hour, "time without requests after which a game is removed, 0 to keep games")
+	maxGames := flag.Int("max-games", 1000, "number of games kept, the least recently active is removed for a new one past it, 0 for no limit")
+	cacheSize := flag.Int("cache", defaultCacheSize, "number of positions kept in the search cache")
+	flag.StringVar(&adminToken, "admin-token", os.Getenv("ADMIN_TOKEN"), "token of the admin endpoints, sent as Authorization: Bearer <token>, they are off when empty")
+	flag.Parse()
+
+	if *weightsPath != "" {
+		weights, err := loadWeights(*weightsPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		engineOptions.Evaluator = weights
 	}
-}
-
-func (board Board) getAsSlice() (boardList [][]string) {
-	for i := 0; i < len(board); i++ {
-		rowList := []string{}
-		for j := 0; j < len(board[i]); j++ {
-			rowList = append(rowList, board[i][j].String())
+	if *networkPath != "" {
+		network, err := loadNetwork(*networkPath)
+		if err != nil {
+			log.Fatal(err)
 		}
-		boardList = append(boardList, rowList)
-
+		engineOptions.Evaluator = network
 	}
-	return
-}
-
-func formBoardUsingSlice(boardList [][]string) (board Board) {
-	for i := 0; i < 8; i++ {
-		for j := 0; j < 8; j++ {
-			board[i][j] = &Empty{}
+	if *personalitiesDir != "" {
+		var err error
+		if personalities, err = loadPersonalities(*personalitiesDir); err != nil {
+			log.Fatal(err)
 		}
 	}
-	for i := 0; i < len(boardList); i++ {
-		for j := 0; j < len(boardList[i]); j++ {
-			if boardList[i][j] != (&Empty{}).String() {
-				board[i][j] = getPieceFromString(boardList[i][j])
-			}
+	setCacheSize(*cacheSize)
+	// games are restored after the personalities they use are loaded
+	if *storePath != "" {
+		fileStore, err := newFileStore(*storePath, *maxGames)
+		if err != nil {
+			log.Fatal(err)
 		}
-
+		store = fileStore
+	} else {
+		store = newMemoryStore(*maxGames)
 	}
-	return
-}
-
-func getPieceFromString(pStr string) Piece {
-	switch pStr {
-	case "K":
-		return &King{User}
-	case "K'":
-		return &King{Self}
-	case "Q":
-		return &Queen{User}
-	case "Q'":
-		return &Queen{Self}
-	case "B":
-		return &Bishop{User}
-	case "B'":
-		return &Bishop{Self}
-	case "N":
-		return &Knight{User}
-	case "N'":
-		return &Knight{Self}
-	case "P":
-		return &Pawn{User}
-	case "P'":
-		return &Pawn{Self}
-	case "R":
-		return &Rook{User}
-	case "R'":
-		return &Rook{Self}
-
-	default:
-		return &Empty{}
+	if *ttl > 0 {
+		go expireGames(*ttl, min(*ttl, time.Minute))
 	}
-}
 
-func contains(positions []Position, loc Position) bool {
-	for _, v := range positions {
-		if v == loc {
-			return true
-		}
+	http.HandleFunc("/", play)
+	http.HandleFunc("POST /v1/games", createGame)
+	http.HandleFunc("GET /v1/games/{id}", getGame)
+	http.HandleFunc("DELETE /v1/games/{id}", removeGame)
+	http.HandleFunc("GET /v1/games/{id}/moves", getMoves)
+	http.HandleFunc("POST /v1/games/{id}/moves", postMove)
+	http.HandleFunc("GET /v1/games/{id}/legal-moves", getLegalMoves)
+	http.HandleFunc("POST /v1/games/{id}/undo", undoMove)
+	http.HandleFunc("GET /v1/games/{id}/hint", getHint)
+	http.HandleFunc("POST /v1/games/{id}/join", joinGame)
+	http.HandleFunc("POST /v1/analyse", analysePosition)
+	http.HandleFunc("GET /v1/games/{id}/events", gameEvents)
+	http.HandleFunc("OPTIONS /v1/", preflight)
+	http.HandleFunc("/analyse", analysis)
+	http.HandleFunc("GET /games/{id}/eval", evaluation)
+	http.HandleFunc("GET /games/{id}/attacks", attacked)
+	if adminToken != "" {
+		http.HandleFunc("GET /admin/games", listGames)
+		http.HandleFunc("DELETE /admin/games/{id}", deleteGame)
 	}
-	return false
-}
 
-func enableCors(w *http.ResponseWriter) {
-	(*w).Header().Set("Access-Control-Allow-Origin", "*")
-	(*w).Header().Set("Access-Control-Allow-Headers",
-		"Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+	fmt.Printf("Starting Chess Server...\n")
+	if err := http.ListenAndServe(":8080", nil); err != nil {
+		log.Fatal(err)
+	}
 }
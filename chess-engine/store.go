@@ -0,0 +1,327 @@
+/*
+Contains the stores keeping the games of the server, in memory or in a file.
+*/
+package main
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// store keeps the games, in memory unless the server is started with -store
+var store GameStore = newMemoryStore(0)
+
+// GameStore keeps the games of the server by id, safe to use from concurrent requests
+type GameStore interface {
+	// Get finds the game with the id, counting as activity on it
+	Get(id string) (*Game, bool)
+	// Put adds the game with the id, or saves the changes made to it
+	Put(id string, game *Game) error
+	// Delete removes the game with the id, stopping its searches
+	Delete(id string) error
+	// List gives every game, most recently active first
+	List() []StoredGame
+	// Expire removes the games without activity since the time
+	Expire(since time.Time) error
+}
+
+// StoredGame describes a game of the store
+type StoredGame struct {
+	ID          string    `json:"ID"`
+	LastActive  time.Time `json:"LastActive"`
+	Level       string    `json:"Level,omitempty"`
+	Personality string    `json:"Personality,omitempty"`
+}
+
+// storeEntry is a game of the store with the time of the last request on it
+type storeEntry struct {
+	id         string
+	game       *Game
+	lastActive time.Time
+}
+
+// memoryStore keeps the games in memory, they are lost when the server stops.
+// Past maxGames games, the least recently active one is removed to make room for a new one.
+type memoryStore struct {
+	mutex    sync.Mutex
+	maxGames int
+	// recent has the entries, most recently active first
+	recent  *list.List
+	entries map[string]*list.Element
+}
+
+// newMemoryStore keeps at most maxGames games, any number when 0
+func newMemoryStore(maxGames int) *memoryStore {
+	return &memoryStore{maxGames: maxGames, recent: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (store *memoryStore) Get(id string) (*Game, bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	element, ok := store.entries[id]
+	if !ok {
+		return nil, false
+	}
+	element.Value.(*storeEntry).lastActive = time.Now()
+	store.recent.MoveToFront(element)
+	return element.Value.(*storeEntry).game, true
+}
+
+func (store *memoryStore) Put(id string, game *Game) error {
+	closeGames(store.put(id, game, time.Now()))
+	return nil
+}
+
+// put adds the game active at the time and gives the games removed to make room for it
+func (store *memoryStore) put(id string, game *Game, lastActive time.Time) (removed []*storeEntry) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if element, ok := store.entries[id]; ok {
+		entry := element.Value.(*storeEntry)
+		if entry.game != game {
+			removed = append(removed, &storeEntry{id, entry.game, entry.lastActive})
+		}
+		entry.game, entry.lastActive = game, lastActive
+		store.recent.MoveToFront(element)
+		return
+	}
+	for store.maxGames > 0 && store.recent.Len() >= store.maxGames {
+		removed = append(removed, store.remove(store.recent.Back()))
+	}
+	store.entries[id] = store.recent.PushFront(&storeEntry{id, game, lastActive})
+	return
+}
+
+func (store *memoryStore) Delete(id string) error {
+	closeGames(store.delete(id))
+	return nil
+}
+
+// delete removes the game with the id and gives it, if there is one
+func (store *memoryStore) delete(id string) (removed []*storeEntry) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if element, ok := store.entries[id]; ok {
+		removed = append(removed, store.remove(element))
+	}
+	return
+}
+
+func (store *memoryStore) List() []StoredGame {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	games := []StoredGame{}
+	for element := store.recent.Front(); element != nil; element = element.Next() {
+		entry := element.Value.(*storeEntry)
+		stored := StoredGame{ID: entry.id, LastActive: entry.lastActive, Personality: entry.game.Personality}
+		if entry.game.Level != nil {
+			stored.Level = entry.game.Level.Name
+		}
+		games = append(games, stored)
+	}
+	return games
+}
+
+func (store *memoryStore) Expire(since time.Time) error {
+	closeGames(store.expire(since))
+	return nil
+}
+
+// expire removes the games without activity since the time and gives them
+func (store *memoryStore) expire(since time.Time) (removed []*storeEntry) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	for element := store.recent.Back(); element != nil && element.Value.(*storeEntry).lastActive.Before(since); element = store.recent.Back() {
+		removed = append(removed, store.remove(element))
+	}
+	return
+}
+
+// remove takes the entry out of the store, the caller holds the mutex
+func (store *memoryStore) remove(element *list.Element) *storeEntry {
+	entry := store.recent.Remove(element).(*storeEntry)
+	delete(store.entries, entry.id)
+	return entry
+}
+
+// closeGames stops the searches of the games removed from the store. It is called without
+// holding the mutex of the store, as a game may be locked by a request waiting for a search.
+func closeGames(removed []*storeEntry) {
+	for _, entry := range removed {
+		entry.game.close()
+	}
+}
+
+// fileStore keeps the games in memory and writes all of them to a JSON file on every change,
+// reading them back when the server starts again
+type fileStore struct {
+	*memoryStore
+	path string
+	// saved games as last put, guarded by writing which also serialises the writes of the file
+	saved   map[string]savedGame
+	writing sync.Mutex
+}
+
+// savedGame is a game as written to the file, without the searches running for it.
+// Positions and moves are on the board of the engine.
+type savedGame struct {
+	FEN         string   `json:"FEN"`
+	Start       string   `json:"Start,omitempty"`
+	Moves       []string `json:"Moves,omitempty"`
+	EngineWhite bool     `json:"EngineWhite,omitempty"`
+	Takebacks   int      `json:"Takebacks"`
+	UserClock   *Clock   `json:"UserClock,omitempty"`
+	EngineClock *Clock   `json:"EngineClock,omitempty"`
+	Level       string   `json:"Level,omitempty"`
+	Personality string   `json:"Personality,omitempty"`
+	Seats       *Seats   `json:"Seats,omitempty"`
+	// LastActive keeps the games expiring when they would have without the restart
+	LastActive time.Time `json:"LastActive"`
+}
+
+// newFileStore reads the games of the file at path, if it exists, keeping at most maxGames of them
+func newFileStore(path string, maxGames int) (*fileStore, error) {
+	store := &fileStore{memoryStore: newMemoryStore(maxGames), path: path, saved: map[string]savedGame{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &store.saved); err != nil {
+		return nil, err
+	}
+	// the games are put back least recently active first, so that those are the ones over maxGames removed
+	ids := make([]string, 0, len(store.saved))
+	for id := range store.saved {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return store.saved[ids[i]].LastActive.Before(store.saved[ids[j]].LastActive)
+	})
+	for _, id := range ids {
+		game, err := store.saved[id].restore()
+		if err != nil {
+			return nil, errors.New("Game " + id + ": " + err.Error())
+		}
+		for _, entry := range store.put(id, game, store.saved[id].LastActive) {
+			delete(store.saved, entry.id)
+		}
+	}
+	return store, nil
+}
+
+// Put saves the game as it is now, the caller must keep it from changing meanwhile
+func (store *fileStore) Put(id string, game *Game) error {
+	now := time.Now()
+	removed := store.put(id, game, now)
+	defer closeGames(removed)
+	store.writing.Lock()
+	defer store.writing.Unlock()
+	for _, entry := range removed {
+		delete(store.saved, entry.id)
+	}
+	saved := game.save()
+	saved.LastActive = now
+	store.saved[id] = saved
+	return store.write()
+}
+
+func (store *fileStore) Delete(id string) error {
+	return store.forget(store.delete(id))
+}
+
+func (store *fileStore) Expire(since time.Time) error {
+	return store.forget(store.expire(since))
+}
+
+// forget writes the file without the games removed from memory
+func (store *fileStore) forget(removed []*storeEntry) error {
+	defer closeGames(removed)
+	if len(removed) == 0 {
+		return nil
+	}
+	store.writing.Lock()
+	defer store.writing.Unlock()
+	for _, entry := range removed {
+		delete(store.saved, entry.id)
+	}
+	return store.write()
+}
+
+// write saves every game to the file, replacing it at once so that a crash never leaves half a file
+func (store *fileStore) write() error {
+	data, err := json.Marshal(store.saved)
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(store.path), filepath.Base(store.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), store.path)
+}
+
+// save gives the game as written to the file
+func (game *Game) save() savedGame {
+	s := savedGame{FEN: game.Board.fen(game.ToMove), Start: game.Start, Moves: moveStrings(game.Moves),
+		EngineWhite: game.EngineWhite, Takebacks: game.Takebacks, Personality: game.Personality}
+	if game.Level != nil {
+		s.Level = game.Level.Name
+	}
+	if game.UserClock != nil {
+		userClock, engineClock := *game.UserClock, *game.EngineClock
+		s.UserClock, s.EngineClock = &userClock, &engineClock
+	}
+	if game.Seats != nil {
+		seats := *game.Seats
+		s.Seats = &seats
+	}
+	return s
+}
+
+// restore makes the game written to the file. The clock of the player to move starts again from now.
+func (s savedGame) restore() (*Game, error) {
+	board, player, err := parseFEN(s.FEN)
+	if err != nil {
+		return nil, err
+	}
+	game := &Game{Board: board, ToMove: player, Start: s.Start, EngineWhite: s.EngineWhite, Takebacks: s.Takebacks,
+		UserClock: s.UserClock, EngineClock: s.EngineClock, Personality: s.Personality, Seats: s.Seats, turnStart: time.Now()}
+	for _, move := range s.Moves {
+		parsed, err := parseMove(move)
+		if err != nil {
+			return nil, err
+		}
+		game.Moves = append(game.Moves, parsed)
+	}
+	if s.Level != "" {
+		if game.Level, err = getLevel(s.Level); err != nil {
+			return nil, err
+		}
+	}
+	if s.Personality != "" {
+		weights, ok := personalities[s.Personality]
+		if !ok {
+			return nil, errors.New("Unknown personality " + s.Personality)
+		}
+		game.Weights = weights
+	}
+	return game, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testGame starts a game against the engine, with the user to move
+func testGame(t *testing.T, options GameOptions) *Game {
+	t.Helper()
+	game, err := newGame(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return game
+}
+
+func TestMemoryStoreConcurrentPuts(t *testing.T) {
+	store := newMemoryStore(0)
+	var wg sync.WaitGroup
+	for _, id := range []string{"a", "b", "c", "d"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			store.Put(id, testGame(t, GameOptions{}))
+			store.Get(id)
+		}(id)
+	}
+	wg.Wait()
+	for _, id := range []string{"a", "b", "c", "d"} {
+		if _, ok := store.Get(id); !ok {
+			t.Errorf("%s is missing", id)
+		}
+	}
+}
+
+func TestMemoryStoreRemovesLeastRecentlyActive(t *testing.T) {
+	store := newMemoryStore(2)
+	store.Put("a", testGame(t, GameOptions{}))
+	store.Put("b", testGame(t, GameOptions{}))
+	store.Get("a")
+	store.Put("c", testGame(t, GameOptions{}))
+	if _, ok := store.Get("b"); ok {
+		t.Error("b was kept")
+	}
+	for _, id := range []string{"a", "c"} {
+		if _, ok := store.Get(id); !ok {
+			t.Errorf("%s was removed", id)
+		}
+	}
+	if games := store.List(); len(games) != 2 || games[0].ID != "c" {
+		t.Errorf("got %v, want c then a", games)
+	}
+}
+
+func TestMemoryStoreExpire(t *testing.T) {
+	store := newMemoryStore(0)
+	store.Put("old", testGame(t, GameOptions{}))
+	since := time.Now()
+	store.Put("new", testGame(t, GameOptions{}))
+	store.Expire(since)
+	if _, ok := store.Get("old"); ok {
+		t.Error("old was kept")
+	}
+	if _, ok := store.Get("new"); !ok {
+		t.Error("new was removed")
+	}
+}
+
+func TestFileStoreKeepsGames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.json")
+	store, err := newFileStore(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := testGame(t, GameOptions{EngineColor: "white", Level: "easy", Time: 60})
+	played := testGame(t, GameOptions{})
+	played.push(Move{Position{6, 4}, Position{5, 4}})
+	human := testGame(t, GameOptions{Opponent: "human"})
+	human.Seats.Joined[White] = true
+	for id, game := range map[string]*Game{"timed": game, "played": played, "human": human, "gone": testGame(t, GameOptions{})} {
+		if err := store.Put(id, game); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Delete("gone"); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := newFileStore(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := restored.Get("gone"); ok {
+		t.Error("a deleted game was restored")
+	}
+	for id, want := range map[string]*Game{"timed": game, "played": played} {
+		got, ok := restored.Get(id)
+		if !ok {
+			t.Errorf("%s was not restored", id)
+			continue
+		}
+		if got.fen() != want.fen() || len(got.Moves) != len(want.Moves) || got.engineColor() != want.engineColor() {
+			t.Errorf("%s: got %s %v, want %s %v", id, got.fen(), got.Moves, want.fen(), want.Moves)
+		}
+	}
+	if got, _ := restored.Get("timed"); got.Level == nil || got.Level.Name != "easy" ||
+		got.UserClock == nil || got.UserClock.Remaining != time.Minute {
+		t.Error("the level or the clocks of the game were not restored")
+	}
+	if got, ok := restored.Get("human"); !ok || got.Seats == nil || got.Seats.Tokens != human.Seats.Tokens ||
+		!got.Seats.Joined[White] || got.Seats.Joined[Black] {
+		t.Error("the seats of the game between two players were not restored")
+	}
+}
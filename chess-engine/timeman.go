@@ -0,0 +1,121 @@
+/*
+Contains the time manager deciding how long to think on a move in timed games.
+*/
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// MaxSearchDepth of the MiniMax tree when the search is limited by time
+	MaxSearchDepth = 64
+	// moveOverhead is kept on the clock for the time lost outside of the search
+	moveOverhead = 50 * time.Millisecond
+	// defaultMovesToGo is the number of moves the remaining time is shared between in sudden death
+	defaultMovesToGo = 30
+)
+
+// Clock of a player in a timed game
+type Clock struct {
+	Remaining time.Duration
+	// Increment added after every move
+	Increment time.Duration
+	// MovesToGo until the next time control, 0 when the rest of the game is played on this time
+	MovesToGo int
+}
+
+// timeManager limits the time of a search
+type timeManager struct {
+	mutex sync.Mutex
+	start time.Time
+	// soft limit after which no new depth is searched, stretched while the search is unsure
+	soft time.Duration
+	// hard limit after which the search is stopped
+	hard time.Duration
+	// pondering searches don't count time until the opponent plays the expected move
+	pondering bool
+	// instability grows when the best move changes from one depth to the next
+	instability float64
+}
+
+// newTimeManager shares the time on the clock between the moves left to play
+func newTimeManager(clock Clock) *timeManager {
+	movesToGo := clock.MovesToGo
+	if movesToGo <= 0 {
+		movesToGo = defaultMovesToGo
+	}
+	available := clock.Remaining - moveOverhead
+	if available < 10*time.Millisecond {
+		available = 10 * time.Millisecond
+	}
+
+	soft := available/time.Duration(movesToGo) + clock.Increment*3/4
+	hard := soft * 4
+	if movesToGo > 1 && hard > available*8/10 {
+		hard = available * 8 / 10
+	} else if hard > available {
+		hard = available
+	}
+	if soft > hard {
+		soft = hard
+	}
+	return &timeManager{start: time.Now(), soft: soft, hard: hard}
+}
+
+// fixedTimeManager thinks for the given time on every move
+func fixedTimeManager(moveTime time.Duration) *timeManager {
+	return &timeManager{start: time.Now(), soft: moveTime, hard: moveTime}
+}
+
+// ponder holds the clock until ponderHit
+func (tm *timeManager) ponder() {
+	tm.mutex.Lock()
+	tm.pondering = true
+	tm.mutex.Unlock()
+}
+
+// ponderHit starts the clock of a search started while pondering, the opponent played the expected move
+func (tm *timeManager) ponderHit() {
+	tm.mutex.Lock()
+	if tm.pondering {
+		tm.pondering = false
+		tm.start = time.Now()
+	}
+	tm.mutex.Unlock()
+}
+
+// timeUp tells if the search went past the hard limit
+func (tm *timeManager) timeUp() bool {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	return !tm.pondering && time.Since(tm.start) >= tm.hard
+}
+
+// next tells if there is time to search another depth after best was found, previous being the
+// best line of the depth before. The soft limit is stretched while the best move keeps changing
+// and when the score drops for player, but never past the hard limit.
+func (tm *timeManager) next(player Color, best Line, previous Line) bool {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	if tm.pondering {
+		return true
+	}
+
+	tm.instability /= 2
+	if len(previous.Moves) > 0 && best.Moves[0] != previous.Moves[0] {
+		tm.instability++
+	}
+	scale := 1 + tm.instability/2
+	if len(previous.Moves) > 0 && previous.scoreFor(player)-best.scoreFor(player) >= 0.5 {
+		scale *= 1.5
+	}
+
+	limit := time.Duration(float64(tm.soft) * scale)
+	if limit > tm.hard {
+		limit = tm.hard
+	}
+	// the next depth takes longer than all the ones before it, it could not end in time past half the limit
+	return time.Since(tm.start) < limit/2
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeManagerLimits(t *testing.T) {
+	for _, clock := range []Clock{
+		{Remaining: time.Minute},
+		{Remaining: time.Minute, Increment: 2 * time.Second},
+		{Remaining: 10 * time.Second, MovesToGo: 1},
+		{Remaining: 0},
+	} {
+		tm := newTimeManager(clock)
+		available := max(clock.Remaining-moveOverhead, 10*time.Millisecond)
+		if tm.soft <= 0 || tm.soft > tm.hard || tm.hard > available {
+			t.Errorf("%+v: got soft %v and hard %v with %v available", clock, tm.soft, tm.hard, available)
+		}
+	}
+}
+
+func TestTimeManagerStretchesWhenUnsure(t *testing.T) {
+	same := Line{Moves: []Move{{Position{6, 4}, Position{5, 4}}}}
+	other := Line{Moves: []Move{{Position{6, 3}, Position{5, 3}}}}
+	tm := newTimeManager(Clock{Remaining: time.Minute})
+	// past half the soft limit, another depth is only searched when the best move changed
+	tm.start = time.Now().Add(-tm.soft * 6 / 10)
+	if tm.next(User, same, same) {
+		t.Error("searched another depth with the same best move")
+	}
+	tm.instability = 0
+	if !tm.next(User, same, other) {
+		t.Error("did not search another depth after the best move changed")
+	}
+}
+
+func TestTimeManagerPonder(t *testing.T) {
+	tm := fixedTimeManager(50 * time.Millisecond)
+	tm.ponder()
+	time.Sleep(60 * time.Millisecond)
+	if tm.timeUp() {
+		t.Error("time ran out while pondering")
+	}
+	tm.ponderHit()
+	if tm.timeUp() {
+		t.Error("time ran out at the ponder hit")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if !tm.timeUp() {
+		t.Error("time did not run out after the ponder hit")
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Contains the trace of the evaluation, breaking the score of a board down by term.
+*/
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EvalTerm of the trace of the evaluation, for each side and in total
+type EvalTerm struct {
+	Name string `json:"Name"`
+	Self Term   `json:"Self"`
+	User Term   `json:"User"`
+	// Total of the term for Self, tapered by the phase
+	Total float64 `json:"Total"`
+}
+
+// EvalTrace is the handcrafted evaluation of a board broken down by term, in pawns
+type EvalTrace struct {
+	// Phase of the game, from totalPhase in the opening down to 0
+	Phase int        `json:"Phase"`
+	Terms []EvalTerm `json:"Terms"`
+	// Total for Self, the score of the board
+	Total float64 `json:"Total"`
+}
+
+// trace evaluates the board term by term
+func (board Board) trace(weights *Weights) EvalTrace {
+	var material, kingSafety, activity [2]Term
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			if index := board[i][j].Index(); index != -1 {
+				player := board[i][j].getPlayer()
+				material[player].Mg += weights.Material[index%6]
+				material[player].Eg += weights.Material[index%6]
+			}
+		}
+	}
+	for _, player := range []Color{Self, User} {
+		kingSafety[player].Mg = board.getKingSafety(weights, player)
+		activity[player].Mg, activity[player].Eg = board.getActivity(weights, player)
+	}
+
+	trace := EvalTrace{Phase: board.getPhase()}
+	for _, term := range []struct {
+		name   string
+		scores [2]Term
+	}{
+		{"Material", material},
+		{"Piece-square", board.getPST(weights)},
+		{"Pawns", board.getPawns(weights)},
+		{"King safety", kingSafety},
+		{"Mobility", activity},
+	} {
+		self, user := term.scores[Self], term.scores[User]
+		total := taper(self.Mg-user.Mg, self.Eg-user.Eg, trace.Phase)
+		trace.Terms = append(trace.Terms, EvalTerm{Name: term.name, Self: self, User: user, Total: total})
+		trace.Total += total
+	}
+	return trace
+}
+
+// print shows the trace as a table
+func (trace EvalTrace) print() {
+	fmt.Printf("%-13s| %8s %8s | %8s %8s | %8s\n", "Term", "Self MG", "Self EG", "User MG", "User EG", "Total")
+	fmt.Println(strings.Repeat("-", 13) + "+" + strings.Repeat("-", 19) + "+" + strings.Repeat("-", 19) + "+" + strings.Repeat("-", 9))
+	for _, term := range trace.Terms {
+		fmt.Printf("%-13s| %8.2f %8.2f | %8.2f %8.2f | %+8.2f\n", term.Name, term.Self.Mg, term.Self.Eg, term.User.Mg, term.User.Eg, term.Total)
+	}
+	fmt.Printf("\nPhase: %d/%d\n", trace.Phase, totalPhase)
+	fmt.Printf("Total: %+.2f for Self (black)\n", trace.Total)
+}
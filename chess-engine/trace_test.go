@@ -0,0 +1,31 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTraceAddsUpToEvaluation(t *testing.T) {
+	for _, fen := range []string{
+		startFEN,
+		"rnb1kbnr/pppp1ppp/4p3/6q1/8/5PP1/PPPPP2P/RNBQKBNR w - - 0 1",
+		"7k/6pp/8/8/8/8/6PP/R6K w - - 0 1",
+		"k7/8/8/3pp3/8/8/8/7K w - - 0 1",
+	} {
+		board, _, err := parseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trace := board.trace(defaultWeights)
+		sum := 0.0
+		for _, term := range trace.Terms {
+			sum += term.Total
+		}
+		if math.Abs(sum-trace.Total) > 1e-9 {
+			t.Errorf("%s: the terms add up to %v, not the total %v", fen, sum, trace.Total)
+		}
+		if score := board.evaluate(defaultWeights); math.Abs(trace.Total-score) > 1e-9 {
+			t.Errorf("%s: got a total of %v, want the evaluation %v", fen, trace.Total, score)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+/*
+Contains the training of the evaluation network on positions labelled with the result of their game.
+*/
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// selfPlay plays games of the level against itself and labels every position with the result of its game
+func selfPlay(games int, level *Level) (positions []tuningPosition) {
+	for game := 0; game < games; game++ {
+		var boards []Board
+		result := playGame(level, level, func(board Board) {
+			boards = append(boards, board)
+		})
+		for _, board := range boards {
+			positions = append(positions, tuningPosition{board, result})
+		}
+		fmt.Printf("game %d, %d positions\n", game+1, len(positions))
+	}
+	return
+}
+
+// train fits the network to the positions by stochastic gradient descent for a number of epochs.
+// The network learns the result of the game of each position blended with the winning probability
+// given by the handcrafted evaluation, lambda being the share of the result.
+func train(network *Network, positions []tuningPosition, epochs int, rate float64, lambda float64) {
+	r := rand.New(rand.NewSource(1))
+	targets := make([]float64, len(positions))
+	features := make([][]int, len(positions))
+	for i, position := range positions {
+		// the evaluation is for Self, black
+		targets[i] = lambda*position.result + (1-lambda)*winProbability(-defaultWeights.Evaluate(position.board))
+		for row := 0; row < 8; row++ {
+			for col := 0; col < 8; col++ {
+				if piece := position.board[row][col]; piece.Index() != -1 {
+					features[i] = append(features[i], getFeature(piece, Position{row, col}))
+				}
+			}
+		}
+	}
+
+	accumulator := make([]float32, network.Hidden)
+	for epoch := 1; epoch <= epochs; epoch++ {
+		loss := 0.0
+		for _, i := range r.Perm(len(positions)) {
+			copy(accumulator, network.HiddenBias)
+			for _, feature := range features[i] {
+				network.add(accumulator, feature, 1)
+			}
+			predicted := winProbability(-network.output(accumulator))
+			diff := predicted - targets[i]
+			loss += diff * diff
+
+			// gradient of the squared error by the output of the network
+			gradient := float32(2 * diff * -predicted * (1 - predicted) * math.Ln10 / 4 * rate)
+			for neuron, value := range accumulator {
+				if value > 0 && value < 1 {
+					hidden := gradient * network.OutputWeights[neuron]
+					network.HiddenBias[neuron] -= hidden
+					for _, feature := range features[i] {
+						network.InputWeights[feature*network.Hidden+neuron] -= hidden
+					}
+				}
+				network.OutputWeights[neuron] -= gradient * clippedReLU(value)
+			}
+			network.OutputBias -= gradient
+		}
+		fmt.Printf("epoch %d, error %.6f\n", epoch, loss/float64(len(positions)))
+	}
+	network.salt = rand.Int()
+}
+
+// winProbability of the user with the score in pawns
+func winProbability(score float64) float64 {
+	return 1 / (1 + math.Pow(10, -score/4))
+}
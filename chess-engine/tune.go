@@ -0,0 +1,205 @@
+/*
+Contains the Texel tuning of the evaluation weights on positions labelled with the result of their game.
+*/
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// tuningPosition is a quiet position with the result of its game for the user, white:
+// 1 for a win, 0.5 for a draw and 0 for a loss
+type tuningPosition struct {
+	board  Board
+	result float64
+}
+
+// tuningParam is a weight changed by the tuner, step by step
+type tuningParam struct {
+	value *float64
+	step  float64
+}
+
+// tune optimises weights by local search: every weight is moved up or down a step as long as it lowers
+// the error of the evaluation predicting the results of the positions in the data file, for at most
+// iterations passes over the weights or until none of them moves. The scaling k of the evaluation
+// into a winning probability is fitted to the data first when 0.
+func tune(weights *Weights, data string, k float64, iterations int) (*Weights, error) {
+	positions, err := loadTuningPositions(data)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("%d positions\n", len(positions))
+
+	tuned := *weights
+	if k == 0 {
+		k = fitScaling(&tuned, positions)
+	}
+	best := tuningError(&tuned, positions, k)
+	fmt.Printf("k %.3f, error %.6f\n", k, best)
+
+	params := tuned.params()
+	for iteration := 1; iteration <= iterations; iteration++ {
+		improved := false
+		for _, param := range params {
+			for _, step := range []float64{param.step, -param.step} {
+				*param.value += step
+				tuned.salt = rand.Int()
+				if err := tuningError(&tuned, positions, k); err < best {
+					best, improved = err, true
+					break
+				}
+				*param.value -= step
+			}
+		}
+		tuned.salt = rand.Int()
+		fmt.Printf("iteration %d, error %.6f\n", iteration, best)
+		if !improved {
+			break
+		}
+	}
+	// steps add up with rounding errors
+	for _, param := range params {
+		*param.value = math.Round(*param.value*1e4) / 1e4
+	}
+	return &tuned, nil
+}
+
+// params gives the weights moved by the tuner. The value of the king and the shape of
+// the king attacks and mobility are left alone.
+func (weights *Weights) params() (params []tuningParam) {
+	add := func(step float64, values ...*float64) {
+		for _, value := range values {
+			params = append(params, tuningParam{value, step})
+		}
+	}
+	for i := range weights.Material {
+		if i != (King{Self}).Index() {
+			add(0.05, &weights.Material[i])
+		}
+	}
+	for i := range weights.MgTables {
+		for j := range weights.MgTables[i] {
+			add(2, &weights.MgTables[i][j], &weights.EgTables[i][j])
+		}
+	}
+	for _, term := range []*Term{&weights.Doubled, &weights.Isolated, &weights.Backward, &weights.Blocked, &weights.Connected,
+		&weights.RookSemiOpen, &weights.RookOpen, &weights.RookSeventh, &weights.BishopPair, &weights.Outpost,
+		&weights.TrappedBishop, &weights.TrappedRook} {
+		add(0.01, &term.Mg, &term.Eg)
+	}
+	for i := range weights.Passed {
+		add(0.01, &weights.Passed[i].Mg, &weights.Passed[i].Eg)
+	}
+	for i := range weights.Mobility {
+		add(0.005, &weights.Mobility[i].Mg, &weights.Mobility[i].Eg)
+	}
+	for i := range weights.Shield {
+		add(0.01, &weights.Shield[i])
+	}
+	for i := range weights.Storm {
+		add(0.01, &weights.Storm[i])
+	}
+	for i := range weights.Attack {
+		add(0.01, &weights.Attack[i])
+	}
+	add(0.01, &weights.FreePassed, &weights.SemiOpenFile, &weights.OpenFile)
+	add(0.005, &weights.VirtualMobility)
+	return
+}
+
+// loadTuningPositions reads a data file with a FEN or EPD position per line and the result of its game
+// as 1-0, 0-1 or 1/2-1/2, or as [1.0], [0.5] or [0.0], for white
+func loadTuningPositions(path string) ([]tuningPosition, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var positions []tuningPosition
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		result, err := parseResult(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		board, _, err := parseFEN(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		positions = append(positions, tuningPosition{board, result})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(positions) == 0 {
+		return nil, errors.New("No positions in " + path)
+	}
+	return positions, nil
+}
+
+// parseResult finds the result of the game in a line of the data file
+func parseResult(line string) (float64, error) {
+	switch {
+	case strings.Contains(line, "1/2-1/2"), strings.Contains(line, "[0.5]"):
+		return 0.5, nil
+	case strings.Contains(line, "1-0"), strings.Contains(line, "[1.0]"):
+		return 1, nil
+	case strings.Contains(line, "0-1"), strings.Contains(line, "[0.0]"):
+		return 0, nil
+	}
+	return 0, errors.New("Missing result")
+}
+
+// fitScaling finds the scaling of the evaluation giving the lowest error with the weights
+func fitScaling(weights *Weights, positions []tuningPosition) float64 {
+	best, bestError := 1.0, math.Inf(1)
+	for step := 1.0; step >= 0.01; step /= 10 {
+		for k := math.Max(best-10*step, step); k <= best+10*step; k += step {
+			if err := tuningError(weights, positions, k); err < bestError {
+				best, bestError = k, err
+			}
+		}
+	}
+	return best
+}
+
+// tuningError is the mean squared difference between the results of the positions and the winning
+// probabilities of the user given by the evaluation, computed on every CPU
+func tuningError(weights *Weights, positions []tuningPosition, k float64) float64 {
+	workers := runtime.NumCPU()
+	sums := make([]float64, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(positions); i += workers {
+				// the evaluation is for Self, black
+				score := -positions[i].board.evaluate(weights)
+				diff := positions[i].result - 1/(1+math.Pow(10, -k*score/4))
+				sums[w] += diff * diff
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	total := 0.0
+	for _, sum := range sums {
+		total += sum
+	}
+	return total / float64(len(positions))
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseResult(t *testing.T) {
+	for _, test := range []struct {
+		line   string
+		result float64
+	}{
+		{"7k/8/8/8/8/8/8/Q6K w - - 0 1 1-0", 1},
+		{"7k/8/8/8/8/8/8/q6K w - - 0 1 0-1", 0},
+		{"7k/8/8/8/8/8/8/7K w - - 0 1 1/2-1/2", 0.5},
+		{`7k/8/8/8/8/8/8/Q6K w - - c9 "1-0"; [1.0]`, 1},
+		{"7k/8/8/8/8/8/8/7K w - - [0.5]", 0.5},
+		{"7k/8/8/8/8/8/8/q6K w - - [0.0]", 0},
+	} {
+		if result, err := parseResult(test.line); err != nil || result != test.result {
+			t.Errorf("%s: got %v, %v, want %v", test.line, result, err, test.result)
+		}
+	}
+	if _, err := parseResult("7k/8/8/8/8/8/8/7K w - - 0 1"); err == nil {
+		t.Error("a line without a result was accepted")
+	}
+}
+
+// writeTuningData writes the lines to a data file of the tuner
+func writeTuningData(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "positions.epd")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadTuningPositions(t *testing.T) {
+	path := writeTuningData(t, "# white wins", "7k/8/8/8/8/8/8/Q6K w - - 1-0", "", "7k/8/8/8/8/8/8/q6K w - - 0-1")
+	positions, err := loadTuningPositions(path)
+	if err != nil || len(positions) != 2 || positions[0].result != 1 || positions[1].result != 0 {
+		t.Errorf("got %v, %v, want a win and a loss", positions, err)
+	}
+	for _, lines := range [][]string{
+		{"# no positions"},
+		{"7k/8/8/8/8/8/8/Q6K w - - 1-0", "7k/8/8/8/8/8/8/q6K w - -"},
+		{"7k/8/8/8/8/8/8/X6K w - - 1-0"},
+	} {
+		if _, err := loadTuningPositions(writeTuningData(t, lines...)); err == nil {
+			t.Errorf("%v was accepted", lines)
+		}
+	}
+}
+
+func TestTuneLowersError(t *testing.T) {
+	path := writeTuningData(t,
+		"7k/8/8/8/8/8/P7/7K w - - 1-0",
+		"7k/p7/8/8/8/8/8/7K w - - 0-1",
+		"7k/8/8/8/8/8/8/N6K w - - 1/2-1/2",
+		"7k/8/8/8/8/8/8/R6K w - - 1-0",
+	)
+	positions, err := loadTuningPositions(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tuned, err := tune(defaultWeights, path, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before, after := tuningError(defaultWeights, positions, 1), tuningError(tuned, positions, 1); after >= before {
+		t.Errorf("the error went from %v to %v", before, after)
+	}
+	if tuned.Material[(King{Self}).Index()] != defaultWeights.Material[(King{Self}).Index()] {
+		t.Error("the value of the king was tuned")
+	}
+}
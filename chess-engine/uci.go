@@ -0,0 +1,356 @@
+/*
+Contains the Universal Chess Interface (UCI) to play the engine from chess GUIs.
+*/
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
+
+// uciSearch is the search started by the last "go" command
+type uciSearch struct {
+	stop atomic.Bool
+	// release is closed once the best move can be sent: at once, or after
+	// "ponderhit" when pondering, or after "stop" when searching until told to
+	release  chan struct{}
+	once     sync.Once
+	done     chan struct{}
+	time     *timeManager
+	infinite bool
+}
+
+// uciStrength holds the UCI_LimitStrength and UCI_Elo options
+type uciStrength struct {
+	limit bool
+	elo   int
+}
+
+// level the engine plays at, nil when its strength is not limited
+func (strength uciStrength) level() *Level {
+	if !strength.limit {
+		return nil
+	}
+	return getLevelForElo(strength.elo)
+}
+
+// uci talks to a chess GUI over the Universal Chess Interface on stdin and stdout
+func uci() {
+	board, player, _ := parseFEN(startFEN)
+	options := engineOptions
+	strength := uciStrength{elo: levels[len(levels)-1].Elo}
+	var current *uciSearch
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "uci":
+			fmt.Println("id name Chess-MM")
+			fmt.Println("id author vasusharma7")
+			fmt.Printf("option name Threads type spin default %d min 1 max 256\n", engineOptions.Threads)
+			fmt.Println("option name MultiPV type spin default 1 min 1 max 256")
+			fmt.Println("option name Ponder type check default false")
+			fmt.Println("option name Weights type string default <empty>")
+			fmt.Println("option name EvalFile type string default <empty>")
+			fmt.Println("option name UCI_LimitStrength type check default false")
+			fmt.Printf("option name UCI_Elo type spin default %d min %d max %d\n",
+				strength.elo, levels[0].Elo, levels[len(levels)-1].Elo)
+			fmt.Println("uciok")
+		case "isready":
+			fmt.Println("readyok")
+		case "setoption":
+			setUCIOption(&options, &strength, fields[1:])
+		case "ucinewgame":
+			clearCache()
+		case "position":
+			newBoard, newPlayer, err := parseUCIPosition(fields[1:])
+			if err != nil {
+				fmt.Println("info string", err)
+				continue
+			}
+			board, player = newBoard, newPlayer
+		case "go":
+			current.end()
+			current = uciGo(board, player, options, fields[1:])
+		case "ponderhit":
+			current.ponderHit()
+		case "stop":
+			current.end()
+		case "quit":
+			current.end()
+			return
+		}
+	}
+}
+
+// setUCIOption handles "setoption name <name> value <value>".
+// With UCI_LimitStrength the level of the engine is the one rated closest below UCI_Elo.
+func setUCIOption(options *SearchOptions, strength *uciStrength, fields []string) {
+	var name, value []string
+	for i := 0; i < len(fields); i++ {
+		if fields[i] == "value" {
+			value = fields[i+1:]
+			break
+		}
+		if fields[i] != "name" {
+			name = append(name, fields[i])
+		}
+	}
+
+	switch option := strings.ToLower(strings.Join(name, " ")); option {
+	case "threads", "multipv", "uci_elo":
+		n, err := strconv.Atoi(strings.Join(value, " "))
+		if err != nil || n < 1 {
+			fmt.Println("info string invalid value", strings.Join(value, " "))
+			return
+		}
+		switch option {
+		case "threads":
+			options.Threads = n
+		case "multipv":
+			options.MultiPV = n
+		case "uci_elo":
+			strength.elo = n
+			options.Level = strength.level()
+		}
+	case "uci_limitstrength":
+		strength.limit = strings.Join(value, " ") == "true"
+		options.Level = strength.level()
+	case "weights", "evalfile":
+		path := strings.Join(value, " ")
+		if path == "" || path == "<empty>" {
+			options.Evaluator = engineOptions.Evaluator
+			break
+		}
+		var (
+			evaluator Evaluator
+			err       error
+		)
+		if option == "weights" {
+			evaluator, err = loadWeights(path)
+		} else {
+			evaluator, err = loadNetwork(path)
+		}
+		if err != nil {
+			fmt.Println("info string", err)
+			return
+		}
+		options.Evaluator = evaluator
+	}
+}
+
+// uciScore gives the score of the line for player like UCI info lines: "mate <n>" once a mate is found,
+// with n the moves to the mate from the length of the line, negative when player is mated, and
+// "cp <centipawns>" otherwise
+func uciScore(line Line, player Color) string {
+	score := line.scoreFor(player)
+	if math.Abs(score) < MAX {
+		return fmt.Sprintf("cp %d", int(math.Round(score*100)))
+	}
+	moves := (len(line.Moves) + 1) / 2
+	if score < 0 {
+		moves = -moves
+	}
+	return fmt.Sprintf("mate %d", moves)
+}
+
+// parseUCIPosition reads "[startpos | fen <fen>] moves <move>..." into a board and the player to move
+func parseUCIPosition(fields []string) (board Board, player Color, err error) {
+	if len(fields) == 0 {
+		return board, player, errors.New("missing position")
+	}
+	fen := startFEN
+	rest := fields[1:]
+	if fields[0] == "fen" {
+		i := 1
+		for i < len(fields) && fields[i] != "moves" {
+			i++
+		}
+		fen, rest = strings.Join(fields[1:i], " "), fields[i:]
+	}
+	board, player, err = parseFEN(fen)
+	if err != nil {
+		return
+	}
+	if len(rest) > 0 && rest[0] == "moves" {
+		for _, move := range rest[1:] {
+			if err = applyUCIMove(&board, player, move); err != nil {
+				return
+			}
+			player = opponent(player)
+		}
+	}
+	return
+}
+
+// applyUCIMove plays a move in coordinate notation, like e2e4 or e7e8q.
+// The engine never castles, captures en passant or promotes, but a GUI may send those moves.
+func applyUCIMove(board *Board, player Color, move string) error {
+	if len(move) < 4 || len(move) > 5 || !validateInput(move[:2]) || !validateInput(move[2:4]) {
+		return errors.New("invalid move " + move)
+	}
+	from, to := getPositionFromInput(move[:2]), getPositionFromInput(move[2:4])
+	piece := board[from.row][from.col]
+	if piece.getPlayer() != player {
+		return errors.New("no piece to move for " + move)
+	}
+
+	switch piece.(type) {
+	case *King:
+		if to.col-from.col == 2 {
+			board.makeMove(Position{from.row, 7}, Position{from.row, 5})
+		} else if from.col-to.col == 2 {
+			board.makeMove(Position{from.row, 0}, Position{from.row, 3})
+		}
+	case *Pawn:
+		if from.col != to.col && board[to.row][to.col].getPlayer() == Undefined {
+			board[from.row][to.col] = &Empty{}
+		}
+	}
+	board.makeMove(from, to)
+
+	if len(move) == 5 {
+		promoted := unicode.ToLower(rune(move[4]))
+		if player == User {
+			promoted = unicode.ToUpper(promoted)
+		}
+		if unicode.ToUpper(promoted) == 'K' || getPieceFromFEN(promoted) == nil {
+			return errors.New("invalid promotion " + move)
+		}
+		board[to.row][to.col] = getPieceFromFEN(promoted)
+	}
+	return nil
+}
+
+// uciGo starts searching the position for "go", printing the lines found at every depth.
+// When pondering, the position is the one after the expected move of the opponent and
+// the best move is held back until "ponderhit" or "stop".
+func uciGo(board Board, player Color, options SearchOptions, fields []string) *uciSearch {
+	s := &uciSearch{release: make(chan struct{}), done: make(chan struct{})}
+	var (
+		clock            Clock
+		moveTime         time.Duration
+		ponder, depthSet bool
+	)
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "ponder":
+			ponder = true
+		case "infinite":
+			s.infinite = true
+		case "depth", "wtime", "btime", "winc", "binc", "movestogo", "movetime":
+			if i+1 >= len(fields) {
+				continue
+			}
+			n, err := strconv.Atoi(fields[i+1])
+			if err != nil {
+				continue
+			}
+			ms := time.Duration(n) * time.Millisecond
+			switch fields[i] {
+			case "depth":
+				options.Depth, depthSet = n, true
+			case "wtime":
+				if player == User {
+					clock.Remaining = ms
+				}
+			case "btime":
+				if player == Self {
+					clock.Remaining = ms
+				}
+			case "winc":
+				if player == User {
+					clock.Increment = ms
+				}
+			case "binc":
+				if player == Self {
+					clock.Increment = ms
+				}
+			case "movestogo":
+				clock.MovesToGo = n
+			case "movetime":
+				moveTime = ms
+			}
+			i++
+		}
+	}
+
+	if moveTime > 0 && !s.infinite {
+		s.time = fixedTimeManager(moveTime)
+	} else if clock.Remaining > 0 && !s.infinite {
+		s.time = newTimeManager(clock)
+	}
+	if (s.time != nil || s.infinite) && !depthSet {
+		options.Depth = MaxSearchDepth
+	}
+	options.Time = s.time
+	if ponder && s.time != nil {
+		s.time.ponder()
+	}
+	if !ponder {
+		s.ponderHit()
+	}
+
+	start := time.Now()
+	options.Stop = &s.stop
+	options.Info = func(rank int, line Line) {
+		fmt.Printf("info depth %d multipv %d score %s time %d pv %s\n",
+			line.Depth, rank, uciScore(line, player),
+			time.Since(start).Milliseconds(), strings.Join(moveStrings(line.Moves), " "))
+	}
+	go func() {
+		defer close(s.done)
+		lines := analyse(board, player, options)
+		<-s.release
+		line := lines[0]
+		if options.Level != nil {
+			line = options.Level.pick(lines, player, pickRand(options))
+		}
+		switch moves := line.Moves; {
+		case len(moves) == 0:
+			fmt.Println("bestmove 0000")
+		case len(moves) == 1:
+			fmt.Println("bestmove", moves[0])
+		default:
+			fmt.Println("bestmove", moves[0], "ponder", moves[1])
+		}
+	}()
+	return s
+}
+
+// ponderHit lets the search send its best move, the opponent played the move pondered on
+func (s *uciSearch) ponderHit() {
+	if s != nil {
+		if s.time != nil {
+			s.time.ponderHit()
+		}
+		if !s.infinite {
+			s.send()
+		}
+	}
+}
+
+func (s *uciSearch) send() {
+	s.once.Do(func() { close(s.release) })
+}
+
+// end stops the search and waits for its best move to be sent
+func (s *uciSearch) end() {
+	if s != nil {
+		s.stop.Store(true)
+		s.send()
+		<-s.done
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSetUCIOptionLevel(t *testing.T) {
+	hard, _ := getLevel("hard")
+	options := SearchOptions{Level: hard}
+	strength := uciStrength{elo: levels[len(levels)-1].Elo}
+	for _, test := range []struct {
+		command string
+		level   string
+	}{
+		// only the strength options change the level
+		{"name Threads value 2", "hard"},
+		{"name MultiPV value 3", "hard"},
+		{"name UCI_LimitStrength value true", "expert"},
+		{"name UCI_Elo value 1100", "easy"},
+		{"name Threads value 4", "easy"},
+		{"name UCI_Elo value 0", "easy"},
+		{"name UCI_LimitStrength value false", ""},
+		{"name UCI_Elo value 1400", ""},
+	} {
+		setUCIOption(&options, &strength, strings.Fields(test.command))
+		level := ""
+		if options.Level != nil {
+			level = options.Level.Name
+		}
+		if level != test.level {
+			t.Errorf("%s: got level %q, want %q", test.command, level, test.level)
+		}
+	}
+	if options.Threads != 4 || options.MultiPV != 3 {
+		t.Errorf("got %d threads and %d lines, want 4 and 3", options.Threads, options.MultiPV)
+	}
+}
+
+func TestUCIScore(t *testing.T) {
+	move := Move{Position{1, 4}, Position{2, 4}}
+	for _, test := range []struct {
+		line   Line
+		player Color
+		score  string
+	}{
+		{Line{Moves: []Move{move}, Score: 0.5}, Self, "cp 50"},
+		{Line{Moves: []Move{move}, Score: 0.5}, User, "cp -50"},
+		{Line{Moves: []Move{move}, Score: MAX}, Self, "mate 1"},
+		{Line{Moves: []Move{move, move, move}, Score: MAX}, Self, "mate 2"},
+		{Line{Moves: []Move{move, move}, Score: MAX}, User, "mate -1"},
+		{Line{Moves: []Move{move, move, move, move}, Score: -MAX}, Self, "mate -2"},
+	} {
+		if got := uciScore(test.line, test.player); got != test.score {
+			t.Errorf("%v for %v: got %s, want %s", test.line, test.player, got, test.score)
+		}
+	}
+
+	lines := deterministicLines(t, "7k/6pp/8/8/8/8/6PP/R6K w - - 0 1", 3, 1)
+	if got := uciScore(lines[0], User); got != "mate 1" {
+		t.Errorf("got %s for the mate in one, want mate 1", got)
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Contains the weights of the evaluation, which can be loaded from a JSON file to change how the engine plays.
+*/
+package main
+
+import (
+	"encoding/json"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Term of the evaluation in pawns, for the middlegame (Mg) and the endgame (Eg)
+type Term struct {
+	Mg float64 `json:"Mg"`
+	Eg float64 `json:"Eg"`
+}
+
+// Weights of the evaluation. Arrays by piece are in the order of Piece.Index() % 6:
+// knight, king, queen, rook, bishop, pawn.
+type Weights struct {
+	// Material value of the pieces in pawns
+	Material [6]float64 `json:"Material"`
+	// MgTables and EgTables are the piece-square tables in centipawns, squares laid out
+	// like the board, rank 8 first, for the user and mirrored for Self
+	MgTables [6][64]float64 `json:"MgTables"`
+	EgTables [6][64]float64 `json:"EgTables"`
+
+	// Pawn structure
+	Doubled   Term `json:"Doubled"`
+	Isolated  Term `json:"Isolated"`
+	Backward  Term `json:"Backward"`
+	Blocked   Term `json:"Blocked"`
+	Connected Term `json:"Connected"`
+	// Passed pawns by rank counted from the side of the pawn, rank 1 first
+	Passed [8]Term `json:"Passed"`
+	// FreePassed is the share of the passed pawn bonus added when nothing stands in front of it
+	FreePassed float64 `json:"FreePassed"`
+
+	// King safety, counted in the middlegame only.
+	// Shield for pawns of the king's side one or two ranks in front of it
+	Shield [3]float64 `json:"Shield"`
+	// Storm for enemy pawns coming at the king, by how many ranks away they are
+	Storm [5]float64 `json:"Storm"`
+	// SemiOpenFile and OpenFile for files next to the king without pawns of its side or without any pawn
+	SemiOpenFile float64 `json:"SemiOpenFile"`
+	OpenFile     float64 `json:"OpenFile"`
+	// Attack for each attack on the squares around the king, by piece
+	Attack [6]float64 `json:"Attack"`
+	// AttackersShare of the attacks counted by the number of pieces attacking, one piece alone is no danger
+	AttackersShare [8]float64 `json:"AttackersShare"`
+	// VirtualMobility for each square a queen standing on the king's square could go to
+	VirtualMobility float64 `json:"VirtualMobility"`
+
+	// Piece activity.
+	// Mobility for each square attacked past MobilityBase, by piece
+	Mobility     [6]Term `json:"Mobility"`
+	MobilityBase [6]int  `json:"MobilityBase"`
+	// RookSemiOpen and RookOpen for rooks on files without pawns of their side, or without any pawn
+	RookSemiOpen Term `json:"RookSemiOpen"`
+	RookOpen     Term `json:"RookOpen"`
+	// RookSeventh for rooks on the seventh rank, when the enemy king or pawns are there
+	RookSeventh Term `json:"RookSeventh"`
+	BishopPair  Term `json:"BishopPair"`
+	// Outpost for knights defended by a pawn that no enemy pawn can drive away
+	Outpost Term `json:"Outpost"`
+	// TrappedBishop cut off in the corner of the enemy camp by a pawn,
+	// and TrappedRook shut in by its own uncastled king
+	TrappedBishop Term `json:"TrappedBishop"`
+	TrappedRook   Term `json:"TrappedRook"`
+
+	// salt keeps the scores of these weights apart from the others in the caches
+	salt int
+}
+
+// defaultWeights of the engine
+var defaultWeights = &Weights{
+	Material: [6]float64{3, 200, 9, 5, 3, 1},
+	MgTables: [6][64]float64{mgKnightTable, mgKingTable, mgQueenTable, mgRookTable, mgBishopTable, mgPawnTable},
+	EgTables: [6][64]float64{egKnightTable, egKingTable, egQueenTable, egRookTable, egBishopTable, egPawnTable},
+
+	Doubled:   Term{-0.10, -0.20},
+	Isolated:  Term{-0.10, -0.15},
+	Backward:  Term{-0.08, -0.10},
+	Blocked:   Term{-0.05, -0.05},
+	Connected: Term{0.08, 0.05},
+	Passed: [8]Term{{0, 0}, {0.05, 0.10}, {0.10, 0.15}, {0.15, 0.25},
+		{0.30, 0.45}, {0.50, 0.75}, {0.80, 1.20}, {0, 0}},
+	FreePassed: 0.5,
+
+	Shield:          [3]float64{0, 0.10, 0.05},
+	Storm:           [5]float64{0, 0.05, 0.15, 0.10, 0.05},
+	SemiOpenFile:    0.10,
+	OpenFile:        0.20,
+	Attack:          [6]float64{0.2, 0, 0.8, 0.4, 0.2, 0},
+	AttackersShare:  [8]float64{0, 0, 0.50, 0.75, 0.88, 0.94, 0.97, 0.99},
+	VirtualMobility: 0.02,
+
+	Mobility:      [6]Term{{0.04, 0.04}, {0, 0}, {0.01, 0.02}, {0.02, 0.04}, {0.05, 0.05}, {0, 0}},
+	MobilityBase:  [6]int{4, 0, 13, 7, 6, 0},
+	RookSemiOpen:  Term{0.10, 0.05},
+	RookOpen:      Term{0.25, 0.10},
+	RookSeventh:   Term{0.20, 0.30},
+	BishopPair:    Term{0.30, 0.50},
+	Outpost:       Term{0.30, 0.20},
+	TrappedBishop: Term{-1.00, -1.00},
+	TrappedRook:   Term{-0.50, -0.10},
+}
+
+// Evaluate scores the board with the handcrafted evaluation
+func (weights *Weights) Evaluate(board Board) float64 {
+	return board.evaluate(weights)
+}
+
+func (weights *Weights) key() int {
+	return weights.salt
+}
+
+// engineWeights are the weights loaded with -weights, or the default ones
+func engineWeights() *Weights {
+	if weights, ok := engineOptions.Evaluator.(*Weights); ok {
+		return weights
+	}
+	return defaultWeights
+}
+
+// loadWeights reads weights from a JSON file. Weights missing from the file keep their default value.
+func loadWeights(path string) (*Weights, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	weights := *defaultWeights
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&weights); err != nil {
+		return nil, err
+	}
+	weights.salt = rand.Int()
+	return &weights, nil
+}
+
+// loadPersonalities reads the weights of every JSON file in dir, by the name of the file without extension
+func loadPersonalities(dir string) (map[string]*Weights, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	personalities := make(map[string]*Weights)
+	for _, path := range paths {
+		weights, err := loadWeights(path)
+		if err != nil {
+			return nil, err
+		}
+		personalities[strings.TrimSuffix(filepath.Base(path), ".json")] = weights
+	}
+	return personalities, nil
+}
+
+// save writes the weights to a JSON file
+func (weights *Weights) save(path string) error {
+	data, err := json.MarshalIndent(weights, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
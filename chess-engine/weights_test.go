@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadWeights(t *testing.T) {
+	dir := t.TempDir()
+	for _, test := range []struct {
+		name, json string
+		ok         bool
+	}{
+		{"partial", `{"Doubled": {"Mg": -0.5, "Eg": -0.6}}`, true},
+		{"unknown", `{"Doubled": {"Mg": -0.5, "Eg": -0.6}, "Tripled": {"Mg": -1}}`, false},
+		{"unknown term field", `{"Doubled": {"Mg": -0.5, "Opening": -0.6}}`, false},
+		{"malformed", `{"Doubled": `, false},
+	} {
+		path := filepath.Join(dir, test.name+".json")
+		if err := os.WriteFile(path, []byte(test.json), 0644); err != nil {
+			t.Fatal(err)
+		}
+		weights, err := loadWeights(path)
+		if (err == nil) != test.ok {
+			t.Errorf("%s: got error %v", test.name, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if weights.Doubled != (Term{-0.5, -0.6}) {
+			t.Errorf("%s: got doubled pawns %v", test.name, weights.Doubled)
+		}
+		// weights missing from the file keep their default value
+		if weights.Isolated != defaultWeights.Isolated || weights.Material != defaultWeights.Material {
+			t.Errorf("%s: the weights missing from the file changed", test.name)
+		}
+	}
+}
+
+func TestSaveWeights(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aggressive.json")
+	weights := *defaultWeights
+	weights.Attack[2] = 1.5
+	if err := weights.save(path); err != nil {
+		t.Fatal(err)
+	}
+	personalities, err := loadPersonalities(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, ok := personalities["aggressive"]
+	if !ok {
+		t.Fatalf("got personalities %v, want aggressive", personalities)
+	}
+	if loaded.Attack != weights.Attack || loaded.MgTables != weights.MgTables {
+		t.Errorf("the saved weights changed when loaded")
+	}
+}
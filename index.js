@@ -2,6 +2,8 @@ const server = "https://chess-mm-xtyxpr5tga-uc.a.run.app/"
 const focus = { row: -1, col: -1 }
 let gameId = new Date().getTime()
 let toImgCache = ""
+// squares the focused piece can move to
+let targets = []
 let board = [["R'", "N'", "B'", "K'", "Q'", "B'", "N'", "R'"],
 ["P'", "P'", "P'", "P'", "P'", "P'", "P'", "P'"],
 ["-", "-", "-", "-", "-", "-", "-", "-"],
@@ -95,6 +97,7 @@ document.getElementById("chess-container").addEventListener("click", async (even
         focus.row = row
         focus.col = col
         applyFocus()
+        await highlightTargets(row, col)
     }
     else {
         clearFocus()
@@ -112,20 +115,10 @@ const play = async (from, to) => {
             body: JSON.stringify({ "FromRow": from.row, "FromCol": from.col, "ToRow": to.row, "ToCol": to.col }),
         }).then(async resp => {
             switch (resp.status) {
-                case 406: //not acceptable
+                case 422: // illegal move
+                case 409: // game over or out of time
                     makeMove(to, from,true)
-                    alert(await resp.text())
-                    break
-                case 206: //non authoritative info
-                    makeMove(to, from,true)	
-                    alert(await resp.text())
-                    break
-                case 403: /// forbidden
-                    makeMove(to, from,true)
-                    alert(await resp.text())
-                    break
-                case 204: //no content	
-                    alert(await resp.text())
+                    alert((await resp.json())['Error']['Message'])
                     break
                 case 400:
                     alert("Oops something went wrong in the request")
@@ -141,8 +134,16 @@ const play = async (from, to) => {
 			},500);
 
 		    setTimeout(()=>{
-				if(data['Mate']){
-	 			alert("And Mate :| ");
+				switch (data['Status']) {
+				    case "checkmate": // Mate tells that the user is the one mated
+					alert(data['Mate'] ? "And Mate :| " : "Checkmate, you win !")
+					break
+				    case "stalemate":
+					alert("Stalemate, it is a draw")
+					break
+				    case "timeout": // the user running out of time is refused with 409
+					alert("I ran out of time, you win !")
+					break
 			    }
 			},500);
                     break
@@ -178,7 +179,32 @@ const applyFocus = () => {
     square.getElementsByClassName("square")[0].src = background.replace("gray", "brown")
 }
 
+// squares are named like e2 by the server
+const squareName = (row, col) => String.fromCharCode(97 + col) + (8 - row)
+
+const setSquareColor = (row, col, from, to) => {
+    const square = document.getElementById(`${row}${col}`).getElementsByClassName("square")[0]
+    square.src = square.src.replace(from, to)
+}
+
+// highlights the squares the piece at row, col can legally move to
+const highlightTargets = async (row, col) => {
+    await fetch(server + "v1/games/" + gameId + "/legal-moves?from=" + squareName(row, col))
+        .then(async resp => {
+            if (resp.status != 200) return
+            const data = await resp.json()
+            // the focus may have moved while waiting
+            if (focus.row != row || focus.col != col) return
+            targets = data['Moves'].map(move => ({ row: 8 - Number(move['To'][1]), col: move['To'].charCodeAt(0) - 97 }))
+            targets.forEach(target => setSquareColor(target.row, target.col, "gray", "brown"))
+        }).catch(err => {
+            console.log(err);
+        });
+}
+
 const clearFocus = () => {
+    targets.forEach(target => setSquareColor(target.row, target.col, "brown", "gray"))
+    targets = []
     if (focus.row == -1) return;
     const square = document.getElementById(`${focus.row}${focus.col}`)
     let background = square.getElementsByClassName("square")[0].src
@@ -187,10 +213,19 @@ const clearFocus = () => {
     focus.col = -1
 }
 
+// options of a new game given in the page URL
+const gameOptions = () => {
+    const params = new URLSearchParams(window.location.search)
+    return ["level", "time", "increment"]
+        .filter(name => params.get(name))
+        .map(name => `&${name}=${params.get(name)}`)
+        .join("")
+}
+
 const fetchBoard = async () => {
     document.getElementById("loader").style.visibility = "visible";
 
-    await fetch(server + "?id=" + gameId,
+    await fetch(server + "?id=" + gameId + gameOptions(),
         {
             method: 'GET',
 
@@ -215,6 +250,21 @@ const fetchBoard = async () => {
 
 
 
+// shows what the engine is thinking while it searches for its move
+const followGame = () => {
+    const events = new EventSource(server + "v1/games/" + gameId + "/events")
+    const thinking = document.getElementById("thinking")
+    events.addEventListener("search", event => {
+        const data = JSON.parse(event.data)
+        thinking.textContent = `depth ${data['Depth']}  ${data['Score'].toFixed(2)}  ${data['PV'].join(" ")}`
+    })
+    events.addEventListener("move", event => {
+        if (JSON.parse(event.data)['Player'] == "engine") {
+            thinking.textContent = ""
+        }
+    })
+}
+
 const initialise = async () => {
     const params = new Proxy(new URLSearchParams(window.location.search), {
         get: (searchParams, prop) => searchParams.get(prop),
@@ -223,9 +273,10 @@ const initialise = async () => {
         gameId = params.id
     } else {
         gameId = new Date().getTime()
-        window.location = window.location + "?id=" + gameId
+        window.location = window.location + (window.location.search ? "&id=" : "?id=") + gameId
     }
     await fetchBoard()
+    followGame()
 }
 
 (async () => initialise())()